@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/connections"
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+	types "fampay-assignment/types"
+)
+
+// runBackfillCommand runs a historical backfill in the foreground instead of
+// starting the server:
+//
+//	main backfill -query news -from 2024-10-01T00:00:00Z -to 2024-10-15T00:00:00Z
+//	main backfill -resume 3
+func runBackfillCommand(args []string) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	query := flags.String("query", config.YOUTUBE_SEARCH_QUERY, "search query to backfill")
+	from := flags.String("from", "", "oldest publish time to fetch ("+config.DATE_FORMAT+")")
+	to := flags.String("to", "", "newest publish time to fetch, defaults to now ("+config.DATE_FORMAT+")")
+	budget := flags.Int("budget", 0, "quota units this run may spend, defaults to a share of the keys' daily quota")
+	resume := flags.Int("resume", 0, "id of a paused backfill job to resume")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	db, ok := connections.GetPostgresDb()
+	if !ok {
+		logger.Log.Error("postgres connection not found")
+		return 1
+	}
+
+	jobID := *resume
+	if jobID == 0 {
+		if *to == "" {
+			*to = time.Now().UTC().Format(config.DATE_FORMAT)
+		}
+		request := types.CreateBackfillRequest{
+			Query:       strings.TrimSpace(*query),
+			From:        *from,
+			To:          *to,
+			QuotaBudget: *budget,
+		}
+		if err := request.Validate(); err != nil {
+			logger.Log.WithError(err).Error("invalid backfill arguments")
+			return 2
+		}
+
+		rangeStart, _ := time.Parse(config.DATE_FORMAT, request.From)
+		rangeEnd, _ := time.Parse(config.DATE_FORMAT, request.To)
+		if !rangeStart.Before(rangeEnd) {
+			logger.Log.Error("from must be before to")
+			return 2
+		}
+		if request.QuotaBudget == 0 {
+			request.QuotaBudget = lib.DefaultBackfillQuotaBudget()
+		}
+
+		job, err := lib.CreateBackfillJob(db, request.Query, rangeStart, rangeEnd, request.QuotaBudget)
+		if err != nil {
+			logger.Log.WithError(err).Error("failed to create backfill job")
+			return 1
+		}
+		jobID = job.ID
+	}
+
+	job, err := lib.ClaimBackfillJob(db, jobID)
+	if err != nil {
+		logger.Log.WithField("job", jobID).WithError(err).Error("failed to claim backfill job")
+		return 1
+	}
+	job, err = lib.RunBackfillJob(db, job)
+	if err != nil || job.Status != models.BackfillCompleted {
+		logger.Log.WithFields(logger.Fields{
+			"job":    job.ID,
+			"status": job.Status,
+			"err":    err,
+		}).Error("backfill did not complete, resume it with -resume")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+// Command token prints an HMAC signed bearer token for AUTH_TOKEN_SECRET:
+//
+//	go run ./cmd/token -subject ops -scope admin -ttl 720h
+//
+// It only reads AUTH_TOKEN_SECRET, from the environment or .env, and never
+// touches the database, Redis or the YouTube keys.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"fampay-assignment/authtoken"
+	"fampay-assignment/logger"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	subject := flags.String("subject", "", "who the token is issued to")
+	scope := flags.String("scope", authtoken.ScopeAdmin, "scope the token grants ("+authtoken.ScopeAdmin+", "+authtoken.ScopeRead+" or "+authtoken.ScopeMetrics+")")
+	ttl := flags.Duration("ttl", authtoken.DefaultTTL, "how long the token is valid, 0 for no expiry")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *subject == "" || (*scope != authtoken.ScopeAdmin && *scope != authtoken.ScopeRead && *scope != authtoken.ScopeMetrics) {
+		logger.Log.Error("token needs a subject and an admin, read or metrics scope")
+		return 2
+	}
+
+	// a missing .env is fine when the secret comes from the environment
+	_ = godotenv.Load()
+
+	claims := authtoken.TokenClaims{Subject: *subject, Scope: *scope}
+	if *ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(*ttl).Unix()
+	}
+	token, err := authtoken.SignToken(os.Getenv("AUTH_TOKEN_SECRET"), claims)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to sign token, is AUTH_TOKEN_SECRET set?")
+		return 1
+	}
+	fmt.Println(token)
+	return 0
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"fampay-assignment/logger"
@@ -17,13 +18,40 @@ var (
 	DataDbUser               string
 	DataDbPassword           string
 	RedisUri                 string
-	NewRelicKey              string
 	DataDbPasswordSecretName string
 	AllowedOrigins           []string
 	DataDbPort               int
 	YoutubeApiKey1           string
 	YoutubeApiKey2           string
 	YoutubeApiKey3           string
+
+	// static bearer tokens granting admin, read or metrics access, the
+	// secret HMAC signed tokens are verified with and whether GET /videos
+	// needs a token
+	AdminTokens     []string
+	ReadTokens      []string
+	MetricsTokens   []string
+	AuthTokenSecret string
+	ProtectVideos   bool
+
+	// let admins fetch videos missing from the database from YouTube with
+	// POST /videos/:id/lookup
+	VideoLookupFallback bool
+
+	// where query results are cached, CACHE_BACKEND_REDIS or
+	// CACHE_BACKEND_MEMORY; REDIS_URI is only needed for the former
+	CacheBackend string
+
+	// entries of the in-process cache in front of the cache backend, 0
+	// disables it
+	LocalCacheSize int
+
+	// where spans go, TRACING_EXPORTER_NONE, TRACING_EXPORTER_STDOUT or
+	// TRACING_EXPORTER_OTLP, the OTLP/HTTP collector URL for the latter and
+	// the share of traces started here that are sampled
+	TracingExporter    string
+	OtlpEndpoint       string
+	TracingSampleRatio = 1.0
 )
 
 var (
@@ -31,8 +59,10 @@ var (
 	REDIS_TIMEOUT        = 1 * time.Second
 	YOUTUBE_SEARCH_QUERY = "news"
 	MAX_PAGINATION_SIZE  = 10
+	MAX_FILTER_VALUES    = 20
+	COUNT_EXACT_LIMIT    = 10000
 	DATE_FORMAT          = "2006-01-02T15:04:05Z"
-	CORS_ALLOWED_METHODS = []string{"GET", "POST", "PATCH", "OPTIONS"}
+	CORS_ALLOWED_METHODS = []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"}
 	CORS_ALLOWED_HEADERS = []string{
 		"Origin",
 		"Content-Length",
@@ -41,7 +71,78 @@ var (
 		"Accept",
 		"Cookie",
 	}
-	CACHE_TTL = 5 * time.Minute
+	// cached results are fresh for CACHE_TTL, then served stale for up to
+	// CACHE_STALE_TTL more while a single background refresh replaces them
+	CACHE_TTL       = 5 * time.Minute
+	CACHE_STALE_TTL = 1 * time.Minute
+
+	// lifetime of results in the in-process cache; instances may disagree
+	// for this long after an invalidation
+	LOCAL_CACHE_TTL = 5 * time.Second
+
+	// how long a video YouTube did not return (or a channel rule hides) is
+	// not looked up again
+	VIDEO_LOOKUP_MISS_TTL = 6 * time.Hour
+
+	// cached results at least this large are stored gzipped
+	CACHE_COMPRESS_MIN_BYTES = 8 << 10
+
+	CACHE_BACKEND_REDIS  = "redis"
+	CACHE_BACKEND_MEMORY = "memory"
+	// entries the memory cache backend holds before evicting the least
+	// recently used
+	MEMORY_CACHE_MAX_ENTRIES = 10000
+
+	TRACING_EXPORTER_NONE   = "none"
+	TRACING_EXPORTER_STDOUT = "stdout"
+	TRACING_EXPORTER_OTLP   = "otlp"
+	TRACING_SERVICE_NAME    = "fampay-assignment"
+
+	// search.list page size (the API caps it at 50) and the number of pages
+	// a single fetch cycle may walk before giving up on reaching the watermark
+	YOUTUBE_MAX_RESULTS         = 50
+	YOUTUBE_MAX_PAGES_PER_CYCLE = 5
+
+	// a persisted watermark older than CATCHUP_THRESHOLD is caught up in
+	// CATCHUP_WINDOW sized windows, never reaching back beyond CATCHUP_MAX_GAP
+	// and walking at most CATCHUP_MAX_PAGES_PER_CYCLE pages per fetch cycle
+	CATCHUP_THRESHOLD            = 100 * time.Minute
+	CATCHUP_WINDOW               = 1 * time.Hour
+	CATCHUP_MAX_PAGES_PER_WINDOW = 10
+	CATCHUP_MAX_PAGES_PER_CYCLE  = 20
+	CATCHUP_MAX_GAP              = 7 * 24 * time.Hour
+
+	// quota units charged per search.list, videos.list, channels.list and
+	// i18nRegions.list call (the latter tests keys) and granted per key per day
+	YOUTUBE_SEARCH_COST        = 100
+	YOUTUBE_VIDEOS_LIST_COST   = 1
+	YOUTUBE_CHANNELS_LIST_COST = 1
+	YOUTUBE_KEY_TEST_COST      = 1
+	YOUTUBE_DAILY_QUOTA        = 10000
+
+	// bounds of the throttled fetch interval and how quickly the estimated
+	// cost of a fetch cycle follows the cost of the latest cycle
+	MIN_FETCH_INTERVAL   = 10 * time.Second
+	MAX_FETCH_INTERVAL   = 10 * time.Minute
+	FETCH_COST_SMOOTHING = 0.2
+
+	// historical backfills walk BACKFILL_WINDOW sized windows backwards; a run
+	// may spend BACKFILL_QUOTA_SHARE of the combined daily quota by default
+	BACKFILL_WINDOW               = 6 * time.Hour
+	BACKFILL_MAX_PAGES_PER_WINDOW = 10
+	BACKFILL_QUOTA_SHARE          = 0.5
+	BACKFILL_STALE_AFTER          = 10 * time.Minute
+
+	// videos without statistics are enriched every ENRICHMENT_INTERVAL; videos
+	// published within STATS_REFRESH_WINDOW get their statistics refreshed
+	// once they are older than STATS_REFRESH_INTERVAL
+	ENRICHMENT_INTERVAL             = 30 * time.Second
+	ENRICHMENT_MAX_BATCHES_PER_TICK = 5
+	STATS_REFRESH_WINDOW            = 72 * time.Hour
+	STATS_REFRESH_INTERVAL          = 1 * time.Hour
+
+	// channel details are refreshed once they are older than this
+	CHANNEL_REFRESH_INTERVAL = 24 * time.Hour
 )
 
 func mustGetEnvVar(name string) string {
@@ -54,6 +155,17 @@ func mustGetEnvVar(name string) string {
 	return val
 }
 
+// getListEnvVar splits an optional comma separated env var.
+func getListEnvVar(name string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func parseEnvs() {
 	err := godotenv.Load()
 	if err != nil {
@@ -63,7 +175,17 @@ func parseEnvs() {
 	}
 
 	Port = mustGetEnvVar("PORT")
-	RedisUri = mustGetEnvVar("REDIS_URI")
+	CacheBackend = os.Getenv("CACHE_BACKEND")
+	switch CacheBackend {
+	case "":
+		CacheBackend = CACHE_BACKEND_REDIS
+	case CACHE_BACKEND_REDIS, CACHE_BACKEND_MEMORY:
+	default:
+		logger.Log.WithField("backend", CacheBackend).Fatal("invalid cache backend")
+	}
+	if CacheBackend == CACHE_BACKEND_REDIS {
+		RedisUri = mustGetEnvVar("REDIS_URI")
+	}
 	dataDbPort := mustGetEnvVar("DATA_DB_PORT")
 	YoutubeApiKey1 = mustGetEnvVar("YOUTUBE_API_KEY1")
 	YoutubeApiKey2 = mustGetEnvVar("YOUTUBE_API_KEY2")
@@ -72,13 +194,50 @@ func parseEnvs() {
 	DataDbPassword = mustGetEnvVar("DATA_DB_PASSWORD")
 	DataDbUser = mustGetEnvVar("DATA_DB_USER")
 	DataDbPort, err = strconv.Atoi(dataDbPort)
-	AllowedOrigins = []string{"*"}
 	if err != nil {
 		logger.Log.WithField("port", dataDbPort).Fatal("invalid data db port")
 	}
 
+	AllowedOrigins = getListEnvVar("ALLOWED_ORIGINS")
+	if len(AllowedOrigins) == 0 {
+		AllowedOrigins = []string{"*"}
+	}
+
+	AdminTokens = getListEnvVar("ADMIN_TOKENS")
+	ReadTokens = getListEnvVar("READ_TOKENS")
+	MetricsTokens = getListEnvVar("METRICS_TOKENS")
+	AuthTokenSecret = os.Getenv("AUTH_TOKEN_SECRET")
+	ProtectVideos = os.Getenv("PROTECT_VIDEOS") == "true"
+	VideoLookupFallback = os.Getenv("VIDEO_LOOKUP_FALLBACK") == "true"
+	if localCacheSize := os.Getenv("LOCAL_CACHE_SIZE"); localCacheSize != "" {
+		LocalCacheSize, err = strconv.Atoi(localCacheSize)
+		if err != nil {
+			logger.Log.WithField("size", localCacheSize).Fatal("invalid local cache size")
+		}
+	}
+	TracingExporter = os.Getenv("TRACING_EXPORTER")
+	switch TracingExporter {
+	case "":
+		TracingExporter = TRACING_EXPORTER_NONE
+	case TRACING_EXPORTER_NONE, TRACING_EXPORTER_STDOUT, TRACING_EXPORTER_OTLP:
+	default:
+		logger.Log.WithField("exporter", TracingExporter).Fatal("invalid tracing exporter")
+	}
+	OtlpEndpoint = os.Getenv("OTLP_ENDPOINT")
+	if sampleRatio := os.Getenv("TRACING_SAMPLE_RATIO"); sampleRatio != "" {
+		TracingSampleRatio, err = strconv.ParseFloat(sampleRatio, 64)
+		if err != nil || TracingSampleRatio < 0 || TracingSampleRatio > 1 {
+			logger.Log.WithField("ratio", sampleRatio).Fatal("invalid tracing sample ratio")
+		}
+	}
+	if len(AdminTokens) == 0 && AuthTokenSecret == "" {
+		logger.Log.Warn("neither ADMIN_TOKENS nor AUTH_TOKEN_SECRET is set, admin endpoints are locked")
+	}
+
 }
 
-func init() {
+// Load reads the configuration from the environment and the .env file, and
+// exits when a required variable is missing or invalid.
+func Load() {
 	parseEnvs()
 }
@@ -11,6 +11,13 @@ var (
 	ctx context.Context = context.Background()
 )
 
+// Connect opens the Postgres pool, and the Redis client when Redis backs the
+// cache. It exits when Postgres cannot be reached.
+func Connect() {
+	connectPostgresDb()
+	connectRedisClient()
+}
+
 func GetContext() context.Context {
 	return utils.GetContextWithTimeout(ctx, config.QUERY_TIMEOUT)
 }
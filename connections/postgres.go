@@ -11,7 +11,6 @@ import (
 
     "github.com/jackc/pgx/v5"
     "github.com/jackc/pgx/v5/pgxpool"
-    "github.com/newrelic/go-agent/v3/integrations/nrpgx5"
 )
 
 var (
@@ -65,7 +64,6 @@ func ConnectPostgres(creds *PostgresCreds) *pgxpool.Pool {
     connectionConfig.HealthCheckPeriod = 1 * time.Minute
 
     connectionConfig.BeforeConnect = func(_ context.Context, config *pgx.ConnConfig) error {
-        config.Tracer = nrpgx5.NewTracer(nrpgx5.WithQueryParameters(false))
         config.ConnectTimeout = 10 * time.Second
         return nil
     }
@@ -114,7 +112,7 @@ func ConnectPostgres(creds *PostgresCreds) *pgxpool.Pool {
     return pool
 }
 
-func init() {
+func connectPostgresDb() {
     pgCreds := &PostgresCreds{
         Host:     config.DataDbHost,
         Port:     uint16(config.DataDbPort),
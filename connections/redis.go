@@ -3,7 +3,6 @@ package connections
 import (
 	"context"
 
-	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
 	"github.com/redis/go-redis/v9"
 
 	"fampay-assignment/config"
@@ -30,12 +29,14 @@ func connectRedis(
 	}
 
 	client := redis.NewClient(redisOptions)
-	client.AddHook(nrredis.NewHook(redisOptions))
 
 	logger.Log.Info("connected to redis")
 	return client
 }
 
-func init() {
-	RedisClient = connectRedis(config.RedisUri)
+func connectRedisClient() {
+	// RedisClient stays nil unless Redis backs the cache
+	if config.CacheBackend == config.CACHE_BACKEND_REDIS {
+		RedisClient = connectRedis(config.RedisUri)
+	}
 }
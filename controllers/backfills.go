@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+	types "fampay-assignment/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func CreateBackfill(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "CreateBackfill"
+
+	var data types.CreateBackfillRequest
+	err := ctx.ShouldBindJSON(&data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	res, err := services.CreateBackfill(db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error creating backfill")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func ListBackfills(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListBackfills"
+
+	res, err := services.ListBackfills(ctx.Request.Context(), db)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing backfills")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func GetBackfill(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "GetBackfill"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.GetBackfill(db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error getting backfill")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func ResumeBackfill(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ResumeBackfill"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.ResumeBackfill(db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error resuming backfill")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
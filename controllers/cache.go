@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func GetCacheStats(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "GetCacheStats"
+
+	res, err := services.GetCacheStats()
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error getting cache stats")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+// FlushCache flushes the query named in the path, or every query when the
+// path names none.
+func FlushCache(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "FlushCache"
+
+	res, err := services.FlushCache(ctx.Param("query"))
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error flushing cache")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
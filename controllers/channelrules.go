@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+	types "fampay-assignment/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func ListChannelRules(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListChannelRules"
+
+	res, err := services.ListChannelRules(ctx.Request.Context(), db)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing channel rules")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func CreateChannelRule(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "CreateChannelRule"
+
+	var data types.CreateChannelRuleRequest
+	err := ctx.ShouldBindJSON(&data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	res, err := services.CreateChannelRule(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error creating channel rule")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func DeleteChannelRule(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "DeleteChannelRule"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.DeleteChannelRule(db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error deleting channel rule")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func PurgeBlockedChannels(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "PurgeBlockedChannels"
+
+	res, err := services.PurgeBlockedChannels(ctx.Request.Context(), db)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error purging blocked channels")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"fampay-assignment/config"
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+	types "fampay-assignment/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func ListChannels(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListChannels"
+
+	var data types.ListChannelsRequest
+	data.SortOrder = ctx.DefaultQuery("sort_order", "desc")
+	data.SortBy = ctx.DefaultQuery("sort_by", "subscriber_count")
+	data.PaginationPage, _ = strconv.Atoi(ctx.DefaultQuery("pagination_page", "1"))
+	data.PaginationSize, _ = strconv.Atoi(ctx.DefaultQuery("pagination_size", strconv.Itoa(config.MAX_PAGINATION_SIZE)))
+	data.Title = ctx.Query("title")
+	res, err := services.ListChannels(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing channels")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func GetChannel(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "GetChannel"
+
+	data := types.GetChannelRequest{ChannelID: ctx.Param("id")}
+	res, err := services.GetChannel(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error getting channel")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func ListChannelVideos(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListChannelVideos"
+
+	// a channel's videos are listed from its first one unless asked otherwise
+	data := bindGetLatestVideosRequest(ctx, time.Unix(0, 0))
+	res, err := services.ListChannelVideos(ctx.Request.Context(), db, ctx.Param("id"), &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing channel videos")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
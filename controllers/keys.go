@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+	types "fampay-assignment/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func GetQuotaUsage(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	return services.GetQuotaUsage(db)
+}
+
+func ListAPIKeys(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListAPIKeys"
+
+	res, err := services.ListAPIKeys(db)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing api keys")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func UpdateAPIKey(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "UpdateAPIKey"
+
+	var data types.UpdateAPIKeyRequest
+	err := ctx.ShouldBindJSON(&data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	data.ID, err = parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.UpdateAPIKey(db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error updating api key")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func DeleteAPIKey(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "DeleteAPIKey"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.DeleteAPIKey(db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error deleting api key")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func TestAPIKey(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "TestAPIKey"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.TestAPIKey(ctx.Request.Context(), db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error testing api key")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
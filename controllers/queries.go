@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	"fampay-assignment/services"
+	types "fampay-assignment/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+func parseIDParam(ctx *gin.Context, controller string) (int, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id < 1 {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": controller,
+			"id":         ctx.Param("id"),
+		}).Error("invalid id")
+		return 0, lib.NewExternalError().BadRequest("invalid id")
+	}
+	return id, nil
+}
+
+func ListTrackedQueries(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "ListTrackedQueries"
+
+	res, err := services.ListTrackedQueries(ctx.Request.Context(), db)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error listing tracked queries")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func CreateTrackedQuery(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "CreateTrackedQuery"
+
+	var data types.CreateTrackedQueryRequest
+	err := ctx.ShouldBindJSON(&data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	res, err := services.CreateTrackedQuery(db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error creating tracked query")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func UpdateTrackedQuery(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "UpdateTrackedQuery"
+
+	var data types.UpdateTrackedQueryRequest
+	err := ctx.ShouldBindJSON(&data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	data.ID, err = parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.UpdateTrackedQuery(db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error updating tracked query")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func DeleteTrackedQuery(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "DeleteTrackedQuery"
+
+	id, err := parseIDParam(ctx, name)
+	if err != nil {
+		return lib.ApiResponse{}, err
+	}
+	res, err := services.DeleteTrackedQuery(db, id)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error deleting tracked query")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fampay-assignment/services"
 	types "fampay-assignment/types"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,20 +15,50 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func GetLatestVideos(
-	ctx *gin.Context,
-	db *pgxpool.Pool,
-) (interface{}, error) {
-	name := "GetLatestVideos"
+// queryList reads a multi-valued query parameter given either repeatedly or
+// comma separated.
+func queryList(ctx *gin.Context, name string) []string {
+	values := []string{}
+	for _, param := range ctx.QueryArray(name) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
 
-	var data types.GetLatestVideosRequest
+// bindGetLatestVideosRequest reads the listing parameters shared by every
+// endpoint that lists videos. defaultPublishedAfter applies only when the
+// client sent neither published_after nor published_before.
+func bindGetLatestVideosRequest(ctx *gin.Context, defaultPublishedAfter time.Time) (data types.GetLatestVideosRequest) {
 	data.SortOrder = ctx.Query("sort_order")
+	data.SortBy = ctx.DefaultQuery("sort_by", "published_at")
 	data.PaginationPage, _ = strconv.Atoi(ctx.Query("pagination_page"))
 	data.PaginationSize, _ = strconv.Atoi(ctx.Query("pagination_size"))
 	data.PublishedAfter = ctx.Query("published_after")
-	if data.PublishedAfter == "" {
-		data.PublishedAfter = time.Now().Add(-20 * time.Hour).Format(config.DATE_FORMAT)
+	data.PublishedBefore = ctx.Query("published_before")
+	if data.PublishedAfter == "" && data.PublishedBefore == "" {
+		data.PublishedAfter = defaultPublishedAfter.UTC().Format(config.DATE_FORMAT)
 	}
+	data.QueryID, _ = strconv.Atoi(ctx.Query("query_id"))
+	data.Cursor = ctx.Query("cursor")
+	data.ChannelIDs = queryList(ctx, "channel_id")
+	data.ChannelTitle = ctx.Query("channel_title")
+	data.IncludeKeywords = queryList(ctx, "include_keywords")
+	data.ExcludeKeywords = queryList(ctx, "exclude_keywords")
+	data.TrackedQuery = ctx.Query("tracked_query")
+	return data
+}
+
+func GetLatestVideos(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "GetLatestVideos"
+
+	data := bindGetLatestVideosRequest(ctx, time.Now().Add(-20*time.Hour))
 	err := data.Validate()
 	if err != nil {
 		logger.Log.WithFields(logrus.Fields{
@@ -37,7 +68,7 @@ func GetLatestVideos(
 		}).Error("invalid request")
 		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
 	}
-	res, err := services.GetLatestVideos(db,&data)
+	res, err := services.GetLatestVideos(ctx.Request.Context(), db, &data)
 	if err != nil {
 		logger.Log.WithFields(logrus.Fields{
 			"controller": name,
@@ -49,6 +80,77 @@ func GetLatestVideos(
 
 }
 
+func SearchVideos(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "SearchVideos"
+
+	var data types.SearchVideosRequest
+	data.Query = ctx.Query("q")
+	data.Mode = ctx.DefaultQuery("mode", "fulltext")
+	data.SortOrder = ctx.DefaultQuery("sort_order", "desc")
+	data.SortBy = ctx.DefaultQuery("sort_by", "relevance")
+	data.PaginationPage, _ = strconv.Atoi(ctx.DefaultQuery("pagination_page", "1"))
+	data.PaginationSize, _ = strconv.Atoi(ctx.DefaultQuery("pagination_size", strconv.Itoa(config.MAX_PAGINATION_SIZE)))
+	data.PublishedAfter = ctx.Query("published_after")
+	data.RecencyWeight, _ = strconv.ParseFloat(ctx.DefaultQuery("recency_weight", "0"), 64)
+	err := data.Validate()
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"data":       data,
+			"err":        err,
+		}).Error("invalid request")
+		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
+	}
+	res, err := services.SearchVideos(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error searching videos")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func GetVideo(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "GetVideo"
+
+	data := types.GetVideoRequest{VideoID: ctx.Param("id")}
+	res, err := services.GetVideo(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error getting video")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
+func LookupVideo(
+	ctx *gin.Context,
+	db *pgxpool.Pool,
+) (interface{}, error) {
+	name := "LookupVideo"
+
+	data := types.GetVideoRequest{VideoID: ctx.Param("id")}
+	res, err := services.LookupVideo(ctx.Request.Context(), db, &data)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"controller": name,
+			"err":        err,
+		}).Error("error looking up video")
+		return lib.ApiResponse{}, err
+	}
+	return res, nil
+}
+
 func AddYoutubeAPIKey(
 	ctx *gin.Context,
 	db *pgxpool.Pool,
@@ -73,7 +175,7 @@ func AddYoutubeAPIKey(
 		}).Error("invalid request")
 		return lib.ApiResponse{}, lib.NewExternalError().BadRequest(err.Error())
 	}
-	response, err := services.AddYoutubeAPIKey(db,&data)
+	response, err := services.AddYoutubeAPIKey(db, &data)
 	if err != nil {
 		logger.Log.WithFields(logrus.Fields{
 			"controller": name,
@@ -83,4 +185,4 @@ func AddYoutubeAPIKey(
 	}
 	return response, nil
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"fampay-assignment/authtoken"
+	"fampay-assignment/config"
+)
+
+func matchesStaticToken(tokens []string, token string) bool {
+	matched := false
+	for _, candidate := range tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// AuthenticateToken resolves a bearer token against the static tokens and the
+// HMAC secret configured through the environment.
+func AuthenticateToken(token string) (authtoken.TokenClaims, error) {
+	if token == "" {
+		return authtoken.TokenClaims{}, authtoken.ErrInvalidToken
+	}
+	if matchesStaticToken(config.AdminTokens, token) {
+		return authtoken.TokenClaims{Subject: "static", Scope: authtoken.ScopeAdmin}, nil
+	}
+	if matchesStaticToken(config.ReadTokens, token) {
+		return authtoken.TokenClaims{Subject: "static", Scope: authtoken.ScopeRead}, nil
+	}
+	if matchesStaticToken(config.MetricsTokens, token) {
+		return authtoken.TokenClaims{Subject: "static", Scope: authtoken.ScopeMetrics}, nil
+	}
+	return authtoken.Verify(config.AuthTokenSecret, token, time.Now())
+}
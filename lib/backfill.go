@@ -0,0 +1,286 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/connections"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+	"fampay-assignment/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// backfillJobColumns expects the job aliased as j and its query as q
+const backfillJobColumns = `
+	j.id, j.query_id, q.query, j.range_start, j.range_end, j.cursor,
+	j.status, j.quota_budget, j.units_used, j.pages, j.inserted,
+	j.last_error, j.created_at, j.updated_at`
+
+var (
+	ErrBackfillJobNotFound = errors.New("backfill job not found")
+	ErrBackfillJobBusy     = errors.New("backfill job is already running or completed")
+)
+
+func scanBackfillJob(row pgx.Row) (job models.BackfillJob, err error) {
+	err = row.Scan(
+		&job.ID,
+		&job.QueryID,
+		&job.Query,
+		&job.RangeStart,
+		&job.RangeEnd,
+		&job.Cursor,
+		&job.Status,
+		&job.QuotaBudget,
+		&job.UnitsUsed,
+		&job.Pages,
+		&job.Inserted,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = ErrBackfillJobNotFound
+	}
+	return job, err
+}
+
+// DefaultBackfillQuotaBudget is the share of the combined daily quota of all
+// configured API keys a single backfill run may spend.
+func DefaultBackfillQuotaBudget() int {
+	return int(float64(ApiKeys.UsableCount()*config.YOUTUBE_DAILY_QUOTA) * config.BACKFILL_QUOTA_SHARE)
+}
+
+func CreateBackfillJob(
+	db *pgxpool.Pool,
+	query string,
+	rangeStart time.Time,
+	rangeEnd time.Time,
+	quotaBudget int,
+) (models.BackfillJob, error) {
+	trackedQuery, err := getOrCreateTrackedQuery(db, query)
+	if err != nil {
+		return models.BackfillJob{}, err
+	}
+
+	return scanBackfillJob(db.QueryRow(
+		connections.GetContext(),
+		`WITH job AS (
+			INSERT INTO backfill_jobs (query_id, range_start, range_end, cursor, quota_budget)
+			VALUES ($1, $2, $3, $3, $4)
+			RETURNING *
+		)
+		SELECT `+backfillJobColumns+`
+		FROM job j
+		JOIN tracked_queries q ON q.id = j.query_id`,
+		trackedQuery.ID,
+		rangeStart,
+		rangeEnd,
+		quotaBudget,
+	))
+}
+
+func GetBackfillJob(db *pgxpool.Pool, id int) (models.BackfillJob, error) {
+	return scanBackfillJob(db.QueryRow(
+		connections.GetContext(),
+		`SELECT `+backfillJobColumns+`
+		FROM backfill_jobs j
+		JOIN tracked_queries q ON q.id = j.query_id
+		WHERE j.id = $1`,
+		id,
+	))
+}
+
+func ListBackfillJobs(ctx context.Context, db *pgxpool.Pool) ([]models.BackfillJob, error) {
+	jobs := []models.BackfillJob{}
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"ListBackfillJobs",
+		`SELECT `+backfillJobColumns+`
+		FROM backfill_jobs j
+		JOIN tracked_queries q ON q.id = j.query_id
+		ORDER BY j.id DESC`,
+	)
+	if err != nil {
+		return jobs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job, err := scanBackfillJob(rows)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimBackfillJob marks the job running so only one runner walks it. Jobs
+// left running by a process that died are reclaimed once they go stale.
+func ClaimBackfillJob(db *pgxpool.Pool, id int) (models.BackfillJob, error) {
+	job, err := scanBackfillJob(db.QueryRow(
+		connections.GetContext(),
+		`WITH job AS (
+			UPDATE backfill_jobs
+			SET status = $2, updated_at = NOW()
+			WHERE id = $1
+				AND (
+					status IN ($3, $4)
+					OR (status = $2 AND updated_at < $5)
+				)
+			RETURNING *
+		)
+		SELECT `+backfillJobColumns+`
+		FROM job j
+		JOIN tracked_queries q ON q.id = j.query_id`,
+		id,
+		models.BackfillRunning,
+		models.BackfillPending,
+		models.BackfillPaused,
+		time.Now().Add(-config.BACKFILL_STALE_AFTER),
+	))
+	if errors.Is(err, ErrBackfillJobNotFound) {
+		if _, getErr := GetBackfillJob(db, id); getErr == nil {
+			err = ErrBackfillJobBusy
+		}
+	}
+	return job, err
+}
+
+func checkpointBackfillJob(db *pgxpool.Pool, job *models.BackfillJob) error {
+	_, err := executeQuery(
+		db,
+		`UPDATE backfill_jobs
+		SET
+			cursor = $2,
+			status = $3,
+			units_used = $4,
+			pages = $5,
+			inserted = $6,
+			last_error = $7,
+			updated_at = NOW()
+		WHERE id = $1`,
+		job.ID,
+		job.Cursor,
+		job.Status,
+		job.UnitsUsed,
+		job.Pages,
+		job.Inserted,
+		job.LastError,
+	)
+	return err
+}
+
+// pauseBackfillJob stops the run with the reason recorded so it can be resumed.
+func pauseBackfillJob(db *pgxpool.Pool, job *models.BackfillJob, reason error) error {
+	message := reason.Error()
+	job.Status = models.BackfillPaused
+	job.LastError = &message
+
+	logger.Log.WithFields(logger.Fields{
+		"job":    job.ID,
+		"query":  job.Query,
+		"cursor": job.Cursor,
+		"reason": message,
+	}).Warn("backfill paused")
+	return checkpointBackfillJob(db, job)
+}
+
+// RunBackfillJob walks a claimed job backwards from its cursor towards
+// range_start in BACKFILL_WINDOW sized windows through the same insert path as
+// the live fetcher, checkpointing after every window. A window cut short moves
+// the cursor only down to the oldest video reached. A run spends at most the
+// job's quota_budget units and pauses when the budget or the API keys run out.
+func RunBackfillJob(db *pgxpool.Pool, job models.BackfillJob) (_ models.BackfillJob, err error) {
+	ctx, span := tracing.Start(
+		context.Background(),
+		"backfill job",
+		attribute.Int("backfill.job", job.ID),
+		attribute.String("backfill.query", job.Query),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("backfill.units_used", job.UnitsUsed))
+		tracing.End(span, err)
+	}()
+
+	trackedQuery := models.TrackedQuery{ID: job.QueryID, Query: job.Query}
+	runUnits := 0
+
+	for job.Cursor.After(job.RangeStart) {
+		maxPages := min(
+			config.BACKFILL_MAX_PAGES_PER_WINDOW,
+			(job.QuotaBudget-runUnits)/config.YOUTUBE_SEARCH_COST,
+		)
+		if maxPages <= 0 {
+			return job, pauseBackfillJob(db, &job, fmt.Errorf("quota budget of %d units spent", job.QuotaBudget))
+		}
+
+		window := fetchWindow{
+			PublishedAfter:  job.Cursor.Add(-config.BACKFILL_WINDOW),
+			PublishedBefore: job.Cursor,
+			MaxPages:        maxPages,
+		}
+		if window.PublishedAfter.Before(job.RangeStart) {
+			window.PublishedAfter = job.RangeStart
+		}
+
+		result, err := fetchAndStoreVideos(ctx, trackedQuery, db, window)
+		logFetchResult(trackedQuery, window, result, "backfill window finished")
+
+		units := result.Pages * config.YOUTUBE_SEARCH_COST
+		runUnits += units
+		job.UnitsUsed += units
+		job.Pages += result.Pages
+		job.Inserted += result.Inserted
+		if err != nil {
+			return job, errors.Join(err, pauseBackfillJob(db, &job, err))
+		}
+
+		// a window cut short by the page cap or the budget resumes below the
+		// oldest video reached, so the rest of it is fetched next
+		if !result.Complete {
+			if result.Oldest.IsZero() || !result.Oldest.Before(job.Cursor) {
+				err := fmt.Errorf("backfill window before %s makes no progress", job.Cursor.Format(config.DATE_FORMAT))
+				return job, errors.Join(err, pauseBackfillJob(db, &job, err))
+			}
+			job.Cursor = result.Oldest
+		} else {
+			job.Cursor = window.PublishedAfter
+		}
+		job.LastError = nil
+		if err := checkpointBackfillJob(db, &job); err != nil {
+			return job, err
+		}
+	}
+
+	job.Status = models.BackfillCompleted
+	logger.Log.WithFields(logger.Fields{
+		"job":       job.ID,
+		"query":     job.Query,
+		"unitsUsed": job.UnitsUsed,
+		"pages":     job.Pages,
+		"inserted":  job.Inserted,
+	}).Info("backfill completed")
+	return job, checkpointBackfillJob(db, &job)
+}
+
+// StartBackfillJob runs a claimed job in the background.
+func StartBackfillJob(db *pgxpool.Pool, job models.BackfillJob) {
+	go func() {
+		if _, err := RunBackfillJob(db, job); err != nil {
+			logger.Log.WithFields(logger.Fields{
+				"job": job.ID,
+				"err": err,
+			}).Error("backfill run stopped")
+		}
+	}()
+}
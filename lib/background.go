@@ -2,215 +2,482 @@ package lib
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
 
 	"fampay-assignment/config"
 	"fampay-assignment/connections"
 	"fampay-assignment/logger"
+	"fampay-assignment/metrics"
 	"fampay-assignment/models"
+	"fampay-assignment/tracing"
 )
 
+var ErrVideoNotFound = errors.New("video not found")
+
 const (
 	dbOperationTimeout = 30 * time.Second
 	maxRetries         = 3
-	httpTimeout        = 30 * time.Second
+	initialLookback    = 100 * time.Minute
 )
 
-var (
-	ApiKeys = NewAPIKeys([]string{
-		config.YoutubeApiKey1,
-		config.YoutubeApiKey2,
-		config.YoutubeApiKey3})
-)
+func executeQuery(db *pgxpool.Pool, query string, queryArgs ...interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbOperationTimeout)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		tag, err := db.Exec(ctx, query, queryArgs...)
+		if err == nil {
+			return tag.RowsAffected(), nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("context error during database operation: %v", ctx.Err())
+		}
+
+		backoffDuration := time.Duration(attempt+1) * 500 * time.Millisecond
+		time.Sleep(backoffDuration)
+	}
 
-type YouTubeResponse struct {
-	Items []struct {
-		ID struct {
-			VideoID string `json:"videoId"`
-		} `json:"id"`
-		Snippet struct {
-			PublishedAt time.Time `json:"publishedAt"`
-			ChannelID   string    `json:"channelId"`
-			Title       string    `json:"title"`
-			Description string    `json:"description"`
-			Thumbnails  struct {
-				High struct {
-					URL string `json:"url"`
-				} `json:"default"`
-			} `json:"thumbnails"`
-			ChannelTitle string `json:"channelTitle"`
-		} `json:"snippet"`
-	} `json:"items"`
-	Error struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Errors  []struct {
-			Message string `json:"message"`
-			Domain  string `json:"domain"`
-			Reason  string `json:"reason"`
-		} `json:"errors"`
-	} `json:"error"`
+	return 0, fmt.Errorf("failed after %d retries: %v", maxRetries, lastErr)
 }
 
-type APIKeys struct {
-	keys    []string
-	currKey int
+// fetchResult reports how much of the search results a fetch cycle consumed.
+type fetchResult struct {
+	Pages    int
+	Items    int
+	Inserted int
+	// videos skipped because of the channel allow/block lists
+	Filtered int
+	// videos skipped because they were published at or before the window's
+	// publishedAfter bound
+	BeforeWatermark int
+	// videos skipped because the query had already surfaced them
+	Known int
+	// videos another query had already stored, now also linked to this one
+	Existing int
+	// videos skipped because storing them failed
+	Failed int
+	// the walk reached the window's publishedAfter bound or the last page.
+	// Otherwise it stopped at the page cap or at a video the query already
+	// surfaced, and videos of the window older than Oldest may be missing.
+	Complete bool
+	// oldest publish time among the items the walk reached inside the window
+	Oldest time.Time
 }
 
-func (k *APIKeys) nextKey() (string, error) {
-	if len(k.keys) == 0 {
-		return "", errors.New("no API keys available")
-	}
-	k.currKey = (k.currKey + 1) % len(k.keys)
-	return k.keys[k.currKey], nil
+// fetchWindow bounds a fetch cycle. A zero PublishedBefore leaves the window
+// open ended; StopOnKnown ends the walk at the first video the query has
+// already surfaced, which is only safe when walking down from the newest.
+type fetchWindow struct {
+	PublishedAfter  time.Time
+	PublishedBefore time.Time
+	MaxPages        int
+	StopOnKnown     bool
 }
 
-func (k *APIKeys) currentKey() (string, error) {
-	if len(k.keys) == 0 {
-		return "", errors.New("no API keys available")
+// storeVideo inserts the video and links it to the query that surfaced it.
+// inserted reports a new videos row, surfaced a new link for this query.
+func storeVideo(db *pgxpool.Pool, queryID int, video models.Video) (inserted bool, surfaced bool, err error) {
+	queryTemplate := `
+		INSERT INTO videos (
+			video_id, title, description, published_at,
+			thumbnail_url, channel_title, channel_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (video_id) DO NOTHING`
+
+	rowsAffected, err := executeQuery(db, queryTemplate,
+		video.VideoID, video.Title, video.Description,
+		video.PublishedAt, video.ThumbnailURL,
+		video.ChannelTitle, video.ChannelID)
+	if err != nil {
+		return false, false, err
+	}
+	inserted = rowsAffected > 0
+	if inserted {
+		if err := storeChannel(db, video.ChannelID, video.ChannelTitle); err != nil {
+			logger.Log.Printf("Failed to store channel %s: %v", video.ChannelID, err)
+		}
 	}
-	return k.keys[k.currKey], nil
+
+	rowsAffected, err = executeQuery(db, `
+		INSERT INTO video_queries (video_id, query_id)
+		VALUES ($1, $2)
+		ON CONFLICT (video_id, query_id) DO NOTHING`,
+		video.VideoID, queryID)
+	return inserted, rowsAffected > 0, err
 }
 
-func (k *APIKeys) removeCurrentKey() {
-	if len(k.keys) > 0 {
-		k.keys = append(k.keys[:k.currKey], k.keys[k.currKey+1:]...)
-		if k.currKey >= len(k.keys) {
-			k.currKey = 0
-		}
+func videoLookupMissKey(videoID string) string {
+	return "videos:lookup-miss:" + videoID
+}
+
+// videoLookupMissed reports whether a lookup of the video found nothing
+// within VIDEO_LOOKUP_MISS_TTL.
+func videoLookupMissed(videoID string) bool {
+	_, err := queryCache.Get(videoLookupMissKey(videoID))
+	if err != nil && !errors.Is(err, ErrCacheMiss) {
+		logger.Log.WithFields(logger.Fields{
+			"video_id": videoID,
+			"err":      err,
+		}).Error("failed to get video lookup miss")
 	}
+	return err == nil
 }
 
-func AddKey(newKey string) (bool, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Log.Println("Recovered in AddKey:", r)
-		}
-	}()
+func rememberVideoLookupMiss(videoID string) {
+	err := queryCache.Set(videoLookupMissKey(videoID), []byte{1}, config.VIDEO_LOOKUP_MISS_TTL)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"video_id": videoID,
+			"err":      err,
+		}).Error("failed to set video lookup miss")
+	}
+}
 
-	for _, existingKey := range ApiKeys.keys {
-		if existingKey == newKey {
-			return true, nil 
-		}
+// FetchAndStoreYouTubeVideo fetches a video that is not stored yet through
+// videos.list and stores it together with its statistics. Videos YouTube does
+// not return are remembered for VIDEO_LOOKUP_MISS_TTL and not asked for again
+// until then.
+//
+// Only the cached lookups of the video and its channel are dropped. Cached
+// listings are left to expire on their own by design: one video is not worth
+// invalidating every cached query for.
+func FetchAndStoreYouTubeVideo(ctx context.Context, db *pgxpool.Pool, videoID string) (models.Video, error) {
+	if videoLookupMissed(videoID) {
+		return models.Video{}, ErrVideoNotFound
 	}
-	
-	if newKey == "" {
-		return false, errors.New("new API key is empty")
+	ytResponse, err := fetchVideos(ctx, []string{videoID}, "snippet,statistics,contentDetails")
+	if err != nil {
+		return models.Video{}, err
+	}
+	if len(ytResponse.Items) == 0 {
+		rememberVideoLookupMiss(videoID)
+		return models.Video{}, ErrVideoNotFound
 	}
 
-	ApiKeys.keys = append(ApiKeys.keys, newKey)
-	return true, nil 
-}
+	item := ytResponse.Items[0]
+	if !loadChannelRules(ctx, db).allows(item.Snippet.ChannelID, item.Snippet.ChannelTitle) {
+		rememberVideoLookupMiss(videoID)
+		return models.Video{}, ErrVideoNotFound
+	}
+	video := models.Video{
+		VideoID:      item.ID,
+		Title:        item.Snippet.Title,
+		Description:  item.Snippet.Description,
+		PublishedAt:  item.Snippet.PublishedAt,
+		ThumbnailURL: item.Snippet.Thumbnails.High.URL,
+		ChannelTitle: item.Snippet.ChannelTitle,
+		ChannelID:    item.Snippet.ChannelID,
+	}
+	applyVideoDetails(&video, item)
+
+	inserted, err := executeQuery(
+		db,
+		`INSERT INTO videos (
+			video_id, title, description, published_at,
+			thumbnail_url, channel_title, channel_id,
+			view_count, like_count, comment_count,
+			duration_seconds, definition, has_captions, stats_updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
+		ON CONFLICT (video_id) DO NOTHING`,
+		video.VideoID, video.Title, video.Description,
+		video.PublishedAt, video.ThumbnailURL,
+		video.ChannelTitle, video.ChannelID,
+		video.ViewCount, video.LikeCount, video.CommentCount,
+		video.DurationSeconds, video.Definition, video.HasCaptions,
+	)
+	if err != nil {
+		return video, err
+	}
+	if err := storeChannel(db, video.ChannelID, video.ChannelTitle); err != nil {
+		logger.Log.Printf("Failed to store channel %s: %v", video.ChannelID, err)
+	}
 
-func NewAPIKeys(keys []string) *APIKeys {
-	return &APIKeys{keys: keys, currKey: 0}
+	params := &GetYouTubeVideoQueryParams{VideoID: videoID}
+	deleteCachedQuery("GetYouTubeVideoQuery", params)
+	if inserted > 0 {
+		deleteCachedQuery("GetChannelQuery", &GetChannelQueryParams{ChannelID: video.ChannelID})
+	}
+	result := getYouTubeVideoQuery(ctx, db, params)
+	if result.Err != nil || !result.Found {
+		return video, result.Err
+	}
+	return result.Video, nil
 }
 
-func executeQuery(db *pgxpool.Pool, query string, queryArgs ...interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbOperationTimeout)
-	defer cancel()
+// fetchAndStoreVideos walks the search result pages of the window newest
+// first until it reaches a video at or before the publishedAfter watermark,
+// a video the query already surfaced (when StopOnKnown is set), the last
+// page, or the window's page cap.
+func fetchAndStoreVideos(ctx context.Context, trackedQuery models.TrackedQuery, db *pgxpool.Pool, window fetchWindow) (result fetchResult, err error) {
+	ctx, span := tracing.Start(
+		ctx,
+		"fetchAndStoreVideos",
+		attribute.String("fetch.query", trackedQuery.Query),
+		attribute.String("fetch.published_after", window.PublishedAfter.UTC().Format(config.DATE_FORMAT)),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("fetch.pages", result.Pages),
+			attribute.Int("fetch.inserted", result.Inserted),
+			attribute.Int("fetch.filtered", result.Filtered),
+		)
+		tracing.End(span, err)
+
+		// query ids, unlike query texts, keep the label set small and fixed
+		queryID := strconv.Itoa(trackedQuery.ID)
+		metrics.VideosInserted.WithLabelValues(queryID).Add(float64(result.Inserted))
+		metrics.VideosSkipped.WithLabelValues(queryID, "filtered").Add(float64(result.Filtered))
+		metrics.VideosSkipped.WithLabelValues(queryID, "watermark").Add(float64(result.BeforeWatermark))
+		metrics.VideosSkipped.WithLabelValues(queryID, "known").Add(float64(result.Known))
+		metrics.VideosSkipped.WithLabelValues(queryID, "existing").Add(float64(result.Existing))
+		metrics.VideosSkipped.WithLabelValues(queryID, "failed").Add(float64(result.Failed))
+
+		// new rows must show up in listings right away, not once CACHE_TTL ends
+		if result.Inserted > 0 {
+			InvalidateCache("")
+		}
+	}()
 
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		_, err := db.Exec(ctx, query, queryArgs...)
-		if err == nil {
-			return nil
+	rules := loadChannelRules(ctx, db)
+	pageToken := ""
+	for result.Pages < window.MaxPages {
+		ytResponse, err := fetchSearchPage(ctx, trackedQuery.Query, window, pageToken)
+		if err != nil {
+			return result, err
 		}
+		result.Pages++
+
+		reachedWatermark := false
+		reachedKnown := false
+		for _, item := range ytResponse.Items {
+			result.Items++
+			if !item.Snippet.PublishedAt.After(window.PublishedAfter) {
+				reachedWatermark = true
+				result.BeforeWatermark++
+				continue
+			}
+			if result.Oldest.IsZero() || item.Snippet.PublishedAt.Before(result.Oldest) {
+				result.Oldest = item.Snippet.PublishedAt
+			}
 
-		lastErr = err
-		if ctx.Err() != nil {
-			return fmt.Errorf("context error during database operation: %v", ctx.Err())
+			video := models.Video{
+				VideoID:      item.ID.VideoID,
+				Title:        item.Snippet.Title,
+				Description:  item.Snippet.Description,
+				PublishedAt:  item.Snippet.PublishedAt,
+				ThumbnailURL: item.Snippet.Thumbnails.High.URL,
+				ChannelTitle: item.Snippet.ChannelTitle,
+				ChannelID:    item.Snippet.ChannelID,
+			}
+			if !rules.allows(video.ChannelID, video.ChannelTitle) {
+				result.Filtered++
+				continue
+			}
+
+			inserted, surfaced, err := storeVideo(db, trackedQuery.ID, video)
+			if err != nil {
+				logger.Log.Printf("Failed to insert video %s: %v", video.VideoID, err)
+				result.Failed++
+				continue
+			}
+			if !surfaced {
+				reachedKnown = reachedKnown || window.StopOnKnown
+				result.Known++
+				continue
+			}
+			if !inserted {
+				result.Existing++
+				continue
+			}
+
+			result.Inserted++
+			logger.Log.Printf("Successfully inserted video %s", video.VideoID)
 		}
 
-		backoffDuration := time.Duration(attempt+1) * 500 * time.Millisecond
-		time.Sleep(backoffDuration)
+		if reachedWatermark || ytResponse.NextPageToken == "" {
+			result.Complete = true
+			return result, nil
+		}
+		if reachedKnown {
+			return result, nil
+		}
+		pageToken = ytResponse.NextPageToken
 	}
 
-	return fmt.Errorf("failed after %d retries: %v", maxRetries, lastErr)
+	logger.Log.WithFields(logger.Fields{
+		"query": trackedQuery.Query,
+		"pages": result.Pages,
+	}).Warn("page cap reached before catching up with the watermark")
+	return result, nil
 }
 
-func fetchAndStoreVideos(searchQuery string, db *pgxpool.Pool, publishedAfter time.Time) error {
-	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
-	defer cancel()
+func logFetchResult(trackedQuery models.TrackedQuery, window fetchWindow, result fetchResult, message string) {
+	logger.Log.WithFields(logger.Fields{
+		"query":           trackedQuery.Query,
+		"publishedAfter":  window.PublishedAfter,
+		"publishedBefore": window.PublishedBefore,
+		"pages":           result.Pages,
+		"items":           result.Items,
+		"inserted":        result.Inserted,
+		"filtered":        result.Filtered,
+		"beforeWatermark": result.BeforeWatermark,
+		"known":           result.Known,
+		"existing":        result.Existing,
+		"failed":          result.Failed,
+	}).Info(message)
+}
 
-	apiKey, err := ApiKeys.currentKey()
-	if err != nil {
-		return err
+// catchUpTrackedQuery closes a gap larger than the normal fetch window by
+// walking it oldest first in bounded windows, persisting the watermark after
+// each one so an interrupted catch-up resumes where it stopped. A window cut
+// short by its page cap is fetched again below the oldest video reached. One
+// call spends at most CATCHUP_MAX_PAGES_PER_CYCLE pages, fewer when the keys
+// have less quota left, and later cycles carry on from the watermark.
+func catchUpTrackedQuery(ctx context.Context, db *pgxpool.Pool, trackedQuery models.TrackedQuery, watermark time.Time) (total fetchResult, err error) {
+	now := time.Now()
+	if oldest := now.Add(-config.CATCHUP_MAX_GAP); watermark.Before(oldest) {
+		logger.Log.WithFields(logger.Fields{
+			"query":     trackedQuery.Query,
+			"watermark": watermark,
+			"oldest":    oldest,
+		}).Warn("watermark is older than the catch-up limit, skipping the rest of the gap")
+		watermark = oldest
 	}
-	publishedAfterStr := publishedAfter.UTC().Format("2006-01-02T15:04:05Z")
-	url := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?key=%s&part=snippet&type=video&order=date&q=%s&publishedAfter=%s",
-		apiKey, searchQuery, publishedAfterStr)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+	pagesLeft := min(
+		config.CATCHUP_MAX_PAGES_PER_CYCLE,
+		ApiKeys.RemainingUnits()/config.YOUTUBE_SEARCH_COST,
+	)
+	for watermark.Before(now) {
+		windowEnd := watermark.Add(config.CATCHUP_WINDOW)
+		if windowEnd.After(now) {
+			windowEnd = now
+		}
+		window := fetchWindow{
+			PublishedAfter:  watermark,
+			PublishedBefore: windowEnd,
+		}
+
+		for {
+			window.MaxPages = min(config.CATCHUP_MAX_PAGES_PER_WINDOW, pagesLeft)
+			if window.MaxPages <= 0 {
+				logger.Log.WithFields(logger.Fields{
+					"query":     trackedQuery.Query,
+					"watermark": watermark,
+				}).Info("catch-up budget of this cycle spent, resuming next cycle")
+				return total, nil
+			}
+
+			result, err := fetchAndStoreVideos(ctx, trackedQuery, db, window)
+			logFetchResult(trackedQuery, window, result, "catch-up window finished")
+			pagesLeft -= result.Pages
+			total.Pages += result.Pages
+			total.Items += result.Items
+			total.Inserted += result.Inserted
+			total.Filtered += result.Filtered
+			total.BeforeWatermark += result.BeforeWatermark
+			total.Known += result.Known
+			total.Existing += result.Existing
+			total.Failed += result.Failed
+			if err != nil {
+				return total, err
+			}
+			if result.Complete {
+				break
+			}
+			if result.Oldest.IsZero() || !result.Oldest.Before(window.PublishedBefore) {
+				return total, fmt.Errorf("catch-up window before %s makes no progress", window.PublishedBefore)
+			}
+			window.PublishedBefore = result.Oldest
+		}
+
+		if err := SetTrackedQueryWatermark(db, trackedQuery.ID, windowEnd); err != nil {
+			return total, err
+		}
+		watermark = windowEnd
 	}
+	return total, nil
+}
+
+// fetchTrackedQueries runs one fetch cycle for every active tracked query,
+// each resuming from its own persisted publishedAfter watermark, and returns
+// the quota units the cycle spent.
+func fetchTrackedQueries(ctx context.Context, db *pgxpool.Pool) (units int) {
+	ctx, span := tracing.Start(ctx, "fetch cycle")
+	start := time.Now()
+	var err error
+	defer func() {
+		metrics.FetchCycleDuration.Observe(time.Since(start).Seconds())
+		span.SetAttributes(attribute.Int("fetch.quota_units", units))
+		tracing.End(span, err)
+	}()
 
-	resp, err := http.DefaultClient.Do(req)
+	trackedQueries, err := ListActiveTrackedQueries(ctx, db)
 	if err != nil {
-		logger.Log.Printf("Request error with key %s: %v. Switching key...", apiKey, err)
-		ApiKeys.removeCurrentKey()
-		return err
+		logger.Log.WithError(err).Error("Error listing tracked queries")
+		return 0
 	}
-	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("fetch.tracked_queries", len(trackedQueries)))
 
-	var ytResponse YouTubeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ytResponse); err != nil {
-		return fmt.Errorf("error decoding YouTube API response: %v", err)
-	}
+	for _, trackedQuery := range trackedQueries {
+		publishedAfter := time.Now().Add(-initialLookback)
+		if trackedQuery.Watermark != nil {
+			publishedAfter = *trackedQuery.Watermark
+		}
 
-	if ytResponse.Error.Code == 403 && ytResponse.Error.Errors[0].Reason == "quotaExceeded" {
-		logger.Log.Printf("Quota exceeded for key. Switching to next key...")
-		_, err := ApiKeys.nextKey()
-		if err != nil {
-			logger.Log.Println("All API keys are exhausted.")
-			return err
-		}
-		return errors.New("quota exceeded, switching key")
-	} else if ytResponse.Error.Code >=400 && ytResponse.Error.Code <= 500 {
-		logger.Log.Printf("Error in YouTube API response: %s. Removing key and switching...", ytResponse.Error.Message)
-		ApiKeys.removeCurrentKey()
-		return fmt.Errorf("API error: %s", ytResponse.Error.Message)
-	}
-
-	for _, item := range ytResponse.Items {
-		video := models.Video{
-			VideoID:      item.ID.VideoID,
-			Title:        item.Snippet.Title,
-			Description:  item.Snippet.Description,
-			PublishedAt:  item.Snippet.PublishedAt,
-			ThumbnailURL: item.Snippet.Thumbnails.High.URL,
-			ChannelTitle: item.Snippet.ChannelTitle,
-			ChannelID:    item.Snippet.ChannelID,
-		}
-
-		queryTemplate := `
-			INSERT INTO videos (
-				video_id, title, description, published_at, 
-				thumbnail_url, channel_title, channel_id
-			)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-			ON CONFLICT (video_id) DO NOTHING`
-
-		err = executeQuery(db, queryTemplate,
-			video.VideoID, video.Title, video.Description,
-			video.PublishedAt, video.ThumbnailURL,
-			video.ChannelTitle, video.ChannelID)
+		if trackedQuery.Watermark != nil && time.Since(publishedAfter) > config.CATCHUP_THRESHOLD {
+			result, err := catchUpTrackedQuery(ctx, db, trackedQuery, publishedAfter)
+			units += result.Pages * config.YOUTUBE_SEARCH_COST
+			if err != nil {
+				logger.Log.WithError(err).Error("Error catching up tracked query")
+			}
+			continue
+		}
 
+		cycleStart := time.Now()
+		window := fetchWindow{
+			PublishedAfter: publishedAfter,
+			MaxPages:       config.YOUTUBE_MAX_PAGES_PER_CYCLE,
+			StopOnKnown:    true,
+		}
+		result, err := fetchAndStoreVideos(ctx, trackedQuery, db, window)
+		logFetchResult(trackedQuery, window, result, "fetch cycle finished")
+		units += result.Pages * config.YOUTUBE_SEARCH_COST
 		if err != nil {
-			logger.Log.Printf("Failed to insert video %s: %v", video.VideoID, err)
+			logger.Log.WithError(err).Error("Error in fetchAndStoreVideos")
 			continue
 		}
-
-		logger.Log.Printf("Successfully inserted video %s", video.VideoID)
+		if !result.Complete {
+			// the videos between the watermark and the oldest one reached are
+			// left to catch-up; moving the watermark would skip them for good
+			logger.Log.WithFields(logger.Fields{
+				"query":     trackedQuery.Query,
+				"watermark": publishedAfter,
+				"oldest":    result.Oldest,
+			}).Warn("fetch cycle stopped short of the watermark, keeping it")
+			if trackedQuery.Watermark == nil {
+				if err := SetTrackedQueryWatermark(db, trackedQuery.ID, publishedAfter); err != nil {
+					logger.Log.WithError(err).Error("Error saving tracked query watermark")
+				}
+			}
+			continue
+		}
+		if err := SetTrackedQueryWatermark(db, trackedQuery.ID, cycleStart); err != nil {
+			logger.Log.WithError(err).Error("Error saving tracked query watermark")
+		}
 	}
-	return nil
+	return units
 }
 
 func StartFetchingVideos(ctx context.Context) {
@@ -220,35 +487,46 @@ func StartFetchingVideos(ctx context.Context) {
 		return
 	}
 
-	searchQuery := config.YOUTUBE_SEARCH_QUERY
-	publishedAfter := time.Now().Add(-100 * time.Minute)
+	if err := seedTrackedQueries(db, config.YOUTUBE_SEARCH_QUERY); err != nil {
+		logger.Log.WithError(err).Error("Error seeding tracked queries")
+	}
 
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+	// the interval between cycles is throttled so that, at the smoothed cost
+	// of recent cycles, the remaining quota of all keys lasts until the reset
+	interval := config.MIN_FETCH_INTERVAL
+	currentFetchInterval.Store(int64(interval))
+	cycleUnits := 0.0
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Log.Info("Stopping video fetch service...")
 			return
-		case <-ticker.C:
-			for len(ApiKeys.keys) == 0 {
+		case <-timer.C:
+			for ApiKeys.ActiveCount() == 0 {
 				logger.Log.Warn("No API keys available, retrying in 10 seconds...")
 				time.Sleep(10 * time.Second)
 			}
-			if err := fetchAndStoreVideos(searchQuery, db, publishedAfter); err != nil {
-				logger.Log.WithError(err).Error("Error in fetchAndStoreVideos")
-				continue
+			units := fetchTrackedQueries(ctx, db)
+			if cycleUnits == 0 {
+				cycleUnits = float64(units)
+			} else {
+				cycleUnits += config.FETCH_COST_SMOOTHING * (float64(units) - cycleUnits)
 			}
-			publishedAfter = time.Now().Add(10 * time.Second)
+
+			remainingUnits := ApiKeys.RemainingUnits()
+			interval = nextFetchInterval(time.Now(), cycleUnits, remainingUnits)
+			currentFetchInterval.Store(int64(interval))
+			logger.Log.WithFields(logger.Fields{
+				"cycleUnits":     units,
+				"avgCycleUnits":  cycleUnits,
+				"remainingUnits": remainingUnits,
+				"interval":       interval.String(),
+			}).Debug("scheduled next fetch cycle")
+			timer.Reset(interval)
 		}
 	}
 }
-
-func init() {
-	ctx := context.Background()
-	go func() {
-		time.Sleep(5 * time.Second)
-		StartFetchingVideos(ctx)
-	}()
-}
@@ -1,32 +1,232 @@
 package lib
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fampay-assignment/config"
 	"fampay-assignment/connections"
 	"fampay-assignment/logger"
-	"fampay-assignment/utils"
+	"fampay-assignment/metrics"
+	"fampay-assignment/tracing"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 )
 
-func GetCache(key string) (string, error) {
-	ctx := connections.GetContext()
+// Cache is a store for encoded query results. Entries are grouped by tags
+// through versions: keys embed the versions of their tags, and bumping a
+// tag's version with InvalidateTag makes every key built from the old one
+// unreachable in O(1); the orphans age out with their TTL.
+type Cache interface {
+	Name() string
+	// Get returns ErrCacheMiss for missing or expired keys.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// TagVersions returns the current version of each tag, 0 for tags never
+	// invalidated.
+	TagVersions(tags ...string) ([]int64, error)
+	InvalidateTag(tag string) (int64, error)
+	// Usage counts the keys starting with prefix and the bytes their values
+	// take.
+	Usage(prefix string) (CacheUsage, error)
+}
+
+type CacheUsage struct {
+	Keys  int64
+	Bytes int64
+}
+
+var (
+	ErrCacheMiss          = errors.New("cache miss")
+	ErrUnknownCachedQuery = errors.New("unknown cached query")
+)
 
-	val, err := connections.RedisClient.Get(ctx, key).Result()
-	return val, err
+// CachedQueries lists the queries cacheQuery caches, by name.
+var CachedQueries = []string{
+	"GetLatestYouTubeVideoQuery",
+	"CountYouTubeVideoQuery",
+	"GetYouTubeVideoQuery",
+	"SearchYouTubeVideoQuery",
+	"ListChannelsQuery",
+	"GetChannelQuery",
 }
 
-func SetCache(key string, val []byte, ttl time.Duration) error {
-	ctx := connections.GetContext()
-	return connections.RedisClient.Set(ctx, key, val, ttl).Err()
+// cacheTagAll tags every cached query result; each result is also tagged
+// with the name of its query.
+const cacheTagAll = "all"
+
+func newQueryCache() Cache {
+	if config.CacheBackend == config.CACHE_BACKEND_MEMORY {
+		return newMemoryCache()
+	}
+	return newRedisCache(connections.RedisClient)
 }
 
-func createCacheKey( op string, params any) (key string, err error) {
-	encodedParams, err := utils.EncodeToGob(params)
+// queryCache is nil until SetupQueryCache runs.
+var queryCache Cache
+
+// SetupQueryCache creates the configured cache backend and the local cache in
+// front of it. It needs connections.Connect to have run.
+func SetupQueryCache() {
+	queryCache = newQueryCache()
+	queryLocalCache = newLocalCache(config.LocalCacheSize, config.LOCAL_CACHE_TTL)
+}
+
+// cacheTagVersions reads tag versions through the local cache, so a local hit
+// costs no round trip to the backend at all.
+func cacheTagVersions(tags ...string) ([]int64, error) {
+	versions := make([]int64, len(tags))
+	missing := false
+	for i, tag := range tags {
+		version, ok := queryLocalCache.get("tag:" + tag)
+		if !ok {
+			missing = true
+			break
+		}
+		versions[i] = version.(int64)
+	}
+	if !missing {
+		return versions, nil
+	}
+
+	versions, err := queryCache.TagVersions(tags...)
+	if err != nil {
+		return versions, err
+	}
+	for i, tag := range tags {
+		queryLocalCache.set("tag:"+tag, versions[i])
+	}
+	return versions, nil
+}
+
+// CacheLayerStats counts lookups in one cache layer since the process started.
+type CacheLayerStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// QueryCacheStats covers the cacheQuery calls of one query since the process
+// started, and the entries of its current version in the backend.
+type QueryCacheStats struct {
+	Query          string
+	Hits           int64
+	Misses         int64
+	Errors         int64
+	AvgHitLatency  time.Duration
+	AvgMissLatency time.Duration
+	Usage          CacheUsage
+}
+
+type CacheStats struct {
+	Backend      string
+	LocalEnabled bool
+	LocalEntries int
+	Local        CacheLayerStats
+	Shared       CacheLayerStats
+	Queries      []QueryCacheStats
+}
+
+var (
+	localCacheHits    atomic.Int64
+	localCacheMisses  atomic.Int64
+	sharedCacheHits   atomic.Int64
+	sharedCacheMisses atomic.Int64
+)
+
+type queryCacheCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	errors    atomic.Int64
+	hitNanos  atomic.Int64
+	missNanos atomic.Int64
+}
+
+var queryCounters sync.Map
+
+func cacheCountersFor(name string) *queryCacheCounters {
+	counters, _ := queryCounters.LoadOrStore(name, &queryCacheCounters{})
+	return counters.(*queryCacheCounters)
+}
+
+func (c *queryCacheCounters) observe(hit bool, latency time.Duration) {
+	if hit {
+		c.hits.Add(1)
+		c.hitNanos.Add(int64(latency))
+	} else {
+		c.misses.Add(1)
+		c.missNanos.Add(int64(latency))
+	}
+}
+
+func averageLatency(total int64, count int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(total / count)
+}
+
+func GetCacheStats() (stats CacheStats, err error) {
+	stats = CacheStats{
+		Backend:      queryCache.Name(),
+		LocalEnabled: queryLocalCache.enabled(),
+		LocalEntries: queryLocalCache.len(),
+		Local: CacheLayerStats{
+			Hits:   localCacheHits.Load(),
+			Misses: localCacheMisses.Load(),
+		},
+		Shared: CacheLayerStats{
+			Hits:   sharedCacheHits.Load(),
+			Misses: sharedCacheMisses.Load(),
+		},
+		Queries: []QueryCacheStats{},
+	}
+
+	for _, name := range CachedQueries {
+		counters := cacheCountersFor(name)
+		queryStats := QueryCacheStats{
+			Query:          name,
+			Hits:           counters.hits.Load(),
+			Misses:         counters.misses.Load(),
+			Errors:         counters.errors.Load(),
+			AvgHitLatency:  averageLatency(counters.hitNanos.Load(), counters.hits.Load()),
+			AvgMissLatency: averageLatency(counters.missNanos.Load(), counters.misses.Load()),
+		}
+
+		prefix, err := cacheKeyPrefix(name)
+		if err != nil {
+			return stats, err
+		}
+		queryStats.Usage, err = queryCache.Usage(prefix)
+		if err != nil {
+			return stats, err
+		}
+		stats.Queries = append(stats.Queries, queryStats)
+	}
+	return stats, nil
+}
+
+// cacheKeyPrefix is shared by every key of the current version of a query.
+func cacheKeyPrefix(op string) (string, error) {
+	versions, err := cacheTagVersions(cacheTagAll, op)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("videos:%s:%d.%d:", op, versions[0], versions[1]), nil
+}
+
+// createCacheKey builds keys like videos:<query>:<tag versions>:<params hash>
+// so they can be told apart with redis-cli.
+func createCacheKey(op string, params any) (key string, err error) {
+	paramsHash, err := canonicalParamsHash(params)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
 			"params": params,
@@ -35,18 +235,56 @@ func createCacheKey( op string, params any) (key string, err error) {
 		return key, err
 	}
 
-	return fmt.Sprintf("videos:%s:%s", op, encodedParams), nil
+	prefix, err := cacheKeyPrefix(op)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"query": op,
+			"err":   err,
+		}).Error("failed to get cache tag versions")
+		return key, err
+	}
+
+	return prefix + paramsHash, nil
 }
 
+// deleteCachedQuery drops the cached result of one query call.
+func deleteCachedQuery(name string, params any) {
+	key, err := createCacheKey(name, params)
+	if err != nil {
+		return
+	}
+	queryLocalCache.delete(key)
+	err = queryCache.Delete(key)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"key": key,
+			"err": err,
+		}).Error("failed to delete cache")
+	}
+}
+
+// cacheFlights coalesces concurrent recomputations of the same cache key.
+var cacheFlights singleflight.Group
+
 func execAndCacheQueryResult[Params any, Result any](
+	ctx context.Context,
 	key string,
-	query func(*pgxpool.Pool, *Params) Result,
+	query func(context.Context, *pgxpool.Pool, *Params) Result,
 	db *pgxpool.Pool,
 	params *Params,
 ) (result Result) {
-	result = query(db, params)
+	result = query(ctx, db, params)
+	if resultErr(result) != nil {
+		return result
+	}
+	queryLocalCache.set(key, result)
 
-	resultEncoded, err := utils.EncodeToGob(result)
+	entry := cacheEntry[Result]{
+		Schema:     resultSchema(reflect.TypeFor[Result]()),
+		FreshUntil: time.Now().Add(config.CACHE_TTL),
+		Result:     result,
+	}
+	entryEncoded, err := encodeCacheEntry(entry)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
 			"result": result,
@@ -55,7 +293,7 @@ func execAndCacheQueryResult[Params any, Result any](
 		return result
 	}
 
-	err = SetCache(key, resultEncoded, config.CACHE_TTL)
+	err = queryCache.Set(key, entryEncoded, config.CACHE_TTL+config.CACHE_STALE_TTL)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
 			"key": key,
@@ -66,12 +304,58 @@ func execAndCacheQueryResult[Params any, Result any](
 	return result
 }
 
+// coalescedQuery runs the query for a key at most once at a time; callers
+// arriving while it runs wait for and share its result.
+func coalescedQuery[Params any, Result any](
+	ctx context.Context,
+	key string,
+	query func(context.Context, *pgxpool.Pool, *Params) Result,
+	db *pgxpool.Pool,
+	params *Params,
+) Result {
+	value, _, _ := cacheFlights.Do(key, func() (any, error) {
+		return execAndCacheQueryResult(ctx, key, query, db, params), nil
+	})
+	return value.(Result)
+}
+
+// refreshQueryResult recomputes a stale entry in the background unless a
+// recomputation of the key is already running. The refresh outlives the
+// request that triggered it, so it gets its own deadline instead of the
+// request's.
+func refreshQueryResult[Params any, Result any](
+	ctx context.Context,
+	key string,
+	query func(context.Context, *pgxpool.Pool, *Params) Result,
+	db *pgxpool.Pool,
+	params *Params,
+) {
+	cacheFlights.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.QUERY_TIMEOUT)
+		defer cancel()
+		return execAndCacheQueryResult(ctx, key, query, db, params), nil
+	})
+}
+
 func cacheQuery[Params any, Result any](
+	ctx context.Context,
 	name string,
-	query func(*pgxpool.Pool, *Params) Result,
+	query func(context.Context, *pgxpool.Pool, *Params) Result,
 	db *pgxpool.Pool,
 	params *Params,
 ) (result Result) {
+	ctx, span := tracing.StartChild(ctx, "cache "+name, attribute.String("cache.backend", queryCache.Name()))
+	counters := cacheCountersFor(name)
+	start := time.Now()
+	// hit, stale, miss or error
+	outcome := "miss"
+	defer func() {
+		counters.observe(outcome == "hit" || outcome == "stale", time.Since(start))
+		metrics.CacheRequests.WithLabelValues(name, outcome).Inc()
+		span.SetAttributes(attribute.String("cache.outcome", outcome))
+		tracing.End(span, resultErr(result))
+	}()
+
 	key, err := createCacheKey(name, params)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
@@ -79,12 +363,24 @@ func cacheQuery[Params any, Result any](
 			"params": params,
 			"err":    err,
 		}).Error("failed to create cache key")
-		return query(db, params)
+		counters.errors.Add(1)
+		outcome = "error"
+		return query(ctx, db, params)
 	}
 
-	cacheResult, err := GetCache(key)
+	if value, ok := queryLocalCache.get(key); ok {
+		localCacheHits.Add(1)
+		outcome = "hit"
+		return value.(Result)
+	}
+	if queryLocalCache.enabled() {
+		localCacheMisses.Add(1)
+	}
+
+	cacheResult, err := queryCache.Get(key)
 	if err != nil {
-		if err == redis.Nil {
+		sharedCacheMisses.Add(1)
+		if errors.Is(err, ErrCacheMiss) {
 			logger.Log.WithFields(logger.Fields{
 				"query": name,
 				"key":   key,
@@ -95,83 +391,85 @@ func cacheQuery[Params any, Result any](
 				"key":   key,
 				"err":   err,
 			}).Error("failed to get from cache")
+			counters.errors.Add(1)
+			outcome = "error"
 		}
-		return execAndCacheQueryResult(key, query, db, params)
+		return coalescedQuery(ctx, key, query, db, params)
 	}
 
-	err = utils.DecodeFromGob([]byte(cacheResult), &result)
+	entry, err := decodeCacheEntry[Result](cacheResult)
+	if errors.Is(err, ErrIncompatibleCacheEntry) {
+		logger.Log.WithFields(logger.Fields{
+			"query": name,
+			"key":   key,
+		}).Info("discarding incompatible cache entry")
+		if err := queryCache.Delete(key); err != nil {
+			logger.Log.WithFields(logger.Fields{
+				"key": key,
+				"err": err,
+			}).Error("failed to delete cache")
+		}
+		sharedCacheMisses.Add(1)
+		return coalescedQuery(ctx, key, query, db, params)
+	}
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
 			"key": key,
 			"err": err,
 		}).Error("failed to decode cached result")
-		return execAndCacheQueryResult(key, query, db, params)
+		counters.errors.Add(1)
+		outcome = "error"
+		sharedCacheMisses.Add(1)
+		return coalescedQuery(ctx, key, query, db, params)
+	}
+	sharedCacheHits.Add(1)
+	outcome = "hit"
+	queryLocalCache.set(key, entry.Result)
+
+	if time.Now().After(entry.FreshUntil) {
+		outcome = "stale"
+		logger.Log.WithFields(logger.Fields{
+			"query": name,
+			"key":   key,
+		}).Info("stale cache hit, refreshing")
+		refreshQueryResult(ctx, key, query, db, params)
+		return entry.Result
 	}
 
 	logger.Log.WithFields(logger.Fields{
 		"query": name,
 		"key":   key,
 	}).Info("cache hit")
-	return result
+	return entry.Result
 }
 
-func InvalidateCache(org string) (keysDeleted int64, err error) {
-	ctx := connections.GetContext()
-
-	const batchSize = 10000
-	var pattern string
-	if org == "" {
-		pattern = "dashboard:*"
-	} else {
-		pattern = fmt.Sprintf("dashboard:%s:*", org)
-	}
-
-	var cursor uint64
-	for {
-		keys, cursor, err := connections.RedisClient.Scan(
-			ctx,
-			cursor,
-			pattern,
-			batchSize,
-		).Result()
-		if err != nil {
-			logger.Log.WithFields(logger.Fields{
-				"org": org,
-				"err": err,
-			}).Error("error scanning keys")
-			return keysDeleted, err
-		}
-
-		if len(keys) == 0 {
-			break
-		}
-
-		deleted, err := connections.RedisClient.Unlink(
-			ctx,
-			keys...,
-		).Result()
-		if err != nil {
-			logger.Log.WithFields(logger.Fields{
-				"org":   org,
-				"count": deleted,
-				"err":   err,
-			}).Error("error unlinking keys")
+// InvalidateCache drops the cached results of one query, or of every query
+// when query is empty, by bumping the version of the matching tag.
+func InvalidateCache(query string) (version int64, err error) {
+	tag := cacheTagAll
+	if query != "" {
+		if !slices.Contains(CachedQueries, query) {
+			return 0, ErrUnknownCachedQuery
 		}
+		tag = query
+	}
 
+	version, err = queryCache.InvalidateTag(tag)
+	if err != nil {
 		logger.Log.WithFields(logger.Fields{
-			"org":   org,
-			"count": deleted,
-		}).Info("unlinked keys")
-		keysDeleted += deleted
-
-		if cursor == 0 {
-			break
-		}
+			"query": query,
+			"err":   err,
+		}).Error("error invalidating cache")
+		return version, err
+	}
+	if query == "" {
+		queryLocalCache.purge()
 	}
+	queryLocalCache.set("tag:"+tag, version)
 
 	logger.Log.WithFields(logger.Fields{
-		"org":   org,
-		"count": keysDeleted,
+		"query":   query,
+		"version": version,
 	}).Info("invalidated cache")
-	return keysDeleted, nil
+	return version, nil
 }
@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type testQueryParams struct {
+	ID int
+}
+
+// useMemoryQueryCache points cacheQuery at a fresh memory backend, without a
+// local cache in front of it, for the duration of the test.
+func useMemoryQueryCache(t *testing.T) *memoryCache {
+	t.Helper()
+	backend, local := queryCache, queryLocalCache
+	t.Cleanup(func() {
+		queryCache, queryLocalCache = backend, local
+	})
+
+	cache := newTestMemoryCache(100)
+	queryCache, queryLocalCache = cache, nil
+	return cache
+}
+
+func seedCachedResult(t *testing.T, params *testQueryParams, value string, freshUntil time.Time) {
+	t.Helper()
+	key, err := createCacheKey("TestQuery", params)
+	if err != nil {
+		t.Fatalf("createCacheKey() error = %v", err)
+	}
+	data, err := encodeCacheEntry(cacheEntry[testCachedResult]{
+		Schema:     resultSchema(reflect.TypeFor[testCachedResult]()),
+		FreshUntil: freshUntil,
+		Result:     testCachedResult{Value: value},
+	})
+	if err != nil {
+		t.Fatalf("encodeCacheEntry() error = %v", err)
+	}
+	if err := queryCache.Set(key, data, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+}
+
+func TestCacheQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		seed func(t *testing.T, params *testQueryParams)
+		// what the query returns when it runs
+		result testCachedResult
+		want   string
+		// queries run by the call itself and by the refresh it starts
+		wantCalls int64
+		// what a second call returns once the first one settled
+		wantNext string
+	}{
+		{
+			name:      "miss runs the query and caches its result",
+			seed:      func(t *testing.T, params *testQueryParams) {},
+			result:    testCachedResult{Value: "fresh"},
+			want:      "fresh",
+			wantCalls: 1,
+			wantNext:  "fresh",
+		},
+		{
+			name: "hit skips the query",
+			seed: func(t *testing.T, params *testQueryParams) {
+				seedCachedResult(t, params, "cached", time.Now().Add(time.Minute))
+			},
+			result:    testCachedResult{Value: "fresh"},
+			want:      "cached",
+			wantCalls: 0,
+			wantNext:  "cached",
+		},
+		{
+			name: "stale hit returns the cached result and refreshes it",
+			seed: func(t *testing.T, params *testQueryParams) {
+				seedCachedResult(t, params, "cached", time.Now().Add(-time.Second))
+			},
+			result:    testCachedResult{Value: "fresh"},
+			want:      "cached",
+			wantCalls: 1,
+			wantNext:  "fresh",
+		},
+		{
+			name: "invalidated entries are missed",
+			seed: func(t *testing.T, params *testQueryParams) {
+				seedCachedResult(t, params, "cached", time.Now().Add(time.Minute))
+				if _, err := InvalidateCache(""); err != nil {
+					t.Fatalf("InvalidateCache() error = %v", err)
+				}
+			},
+			result:    testCachedResult{Value: "fresh"},
+			want:      "fresh",
+			wantCalls: 1,
+			wantNext:  "fresh",
+		},
+		{
+			name: "incompatible entries are replaced",
+			seed: func(t *testing.T, params *testQueryParams) {
+				key, err := createCacheKey("TestQuery", params)
+				if err != nil {
+					t.Fatalf("createCacheKey() error = %v", err)
+				}
+				queryCache.Set(key, []byte(`{"Value":"legacy"}`), time.Minute)
+			},
+			result:    testCachedResult{Value: "fresh"},
+			want:      "fresh",
+			wantCalls: 1,
+			wantNext:  "fresh",
+		},
+		{
+			name:      "failed queries are not cached",
+			seed:      func(t *testing.T, params *testQueryParams) {},
+			result:    testCachedResult{Err: errors.New("connection refused")},
+			want:      "",
+			wantCalls: 1,
+			wantNext:  "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useMemoryQueryCache(t)
+			params := &testQueryParams{ID: 1}
+			tc.seed(t, params)
+
+			var calls atomic.Int64
+			query := func(ctx context.Context, db *pgxpool.Pool, params *testQueryParams) testCachedResult {
+				calls.Add(1)
+				return tc.result
+			}
+
+			result := cacheQuery(context.Background(), "TestQuery", query, nil, params)
+			if result.Value != tc.want {
+				t.Errorf("cacheQuery() = %q, want %q", result.Value, tc.want)
+			}
+			// wait for a background refresh, if one started, to store its result
+			key, err := createCacheKey("TestQuery", params)
+			if err != nil {
+				t.Fatalf("createCacheKey() error = %v", err)
+			}
+			cacheFlights.Do(key, func() (any, error) { return nil, nil })
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("query ran %d times, want %d", got, tc.wantCalls)
+			}
+
+			calls.Store(0)
+			next := cacheQuery(context.Background(), "TestQuery", query, nil, params)
+			if next.Value != tc.wantNext {
+				t.Errorf("second cacheQuery() = %q, want %q", next.Value, tc.wantNext)
+			}
+			wantNextCalls := int64(0)
+			if tc.result.Err != nil {
+				wantNextCalls = 1
+			}
+			if got := calls.Load(); got != wantNextCalls {
+				t.Errorf("second call ran the query %d times, want %d", got, wantNextCalls)
+			}
+		})
+	}
+}
+
+func TestStaleRefreshOutlivesRequest(t *testing.T) {
+	useMemoryQueryCache(t)
+	params := &testQueryParams{ID: 1}
+	seedCachedResult(t, params, "cached", time.Now().Add(-time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	query := func(ctx context.Context, db *pgxpool.Pool, params *testQueryParams) testCachedResult {
+		// the request is gone by the time the refresh runs
+		cancel()
+		if err := ctx.Err(); err != nil {
+			return testCachedResult{Err: err}
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			return testCachedResult{Err: errors.New("refresh has no deadline")}
+		}
+		return testCachedResult{Value: "fresh"}
+	}
+
+	if result := cacheQuery(ctx, "TestQuery", query, nil, params); result.Value != "cached" {
+		t.Fatalf("cacheQuery() = %q, want %q", result.Value, "cached")
+	}
+	key, err := createCacheKey("TestQuery", params)
+	if err != nil {
+		t.Fatalf("createCacheKey() error = %v", err)
+	}
+	cacheFlights.Do(key, func() (any, error) { return nil, nil })
+
+	next := cacheQuery(context.Background(), "TestQuery", query, nil, params)
+	if next.Value != "fresh" {
+		t.Errorf("cacheQuery() after the refresh = %q, want %q", next.Value, "fresh")
+	}
+}
@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"fampay-assignment/config"
+)
+
+// cacheSchemaVersion is part of the schema every cache entry is written with.
+// Bump it when a result keeps its shape but changes meaning; shape changes
+// are caught by the fingerprint of the result type.
+const cacheSchemaVersion = 1
+
+var ErrIncompatibleCacheEntry = errors.New("cache entry written by an incompatible version")
+
+// gzipMagic starts every gzip stream and never starts a JSON document, so it
+// tells compressed entries apart.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// cacheEntry is what cacheQuery stores: the result, the schema it was written
+// with and the time it stops being fresh. The backend keeps the entry for
+// CACHE_STALE_TTL longer than that.
+type cacheEntry[Result any] struct {
+	Schema     string    `json:"schema"`
+	FreshUntil time.Time `json:"fresh_until"`
+	Result     Result    `json:"result"`
+}
+
+// canonicalParamsHash hashes the JSON of the params after a round trip
+// through a generic value, which sorts object keys.
+func canonicalParamsHash(params any) (string, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	var normalized any
+	if err := json.Unmarshal(body, &normalized); err != nil {
+		return "", err
+	}
+	body, err = json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+var resultSchemas sync.Map
+
+// resultSchema identifies the JSON shape of a result type, so entries written
+// by a build whose models differ are discarded instead of half decoded.
+func resultSchema(t reflect.Type) string {
+	if schema, ok := resultSchemas.Load(t); ok {
+		return schema.(string)
+	}
+
+	var shape strings.Builder
+	writeTypeShape(&shape, t, map[reflect.Type]bool{})
+	sum := sha256.Sum256([]byte(shape.String()))
+	schema := fmt.Sprintf("%d.%s", cacheSchemaVersion, hex.EncodeToString(sum[:6]))
+	resultSchemas.Store(t, schema)
+	return schema
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func writeTypeShape(shape *strings.Builder, t reflect.Type, seen map[reflect.Type]bool) {
+	switch {
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		shape.WriteString(t.String())
+		return
+	case seen[t]:
+		shape.WriteString(t.String())
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		shape.WriteString(t.Kind().String() + " ")
+		writeTypeShape(shape, t.Elem(), seen)
+	case reflect.Map:
+		shape.WriteString("map[" + t.Key().String() + "]")
+		writeTypeShape(shape, t.Elem(), seen)
+	case reflect.Struct:
+		seen[t] = true
+		shape.WriteString("{")
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			shape.WriteString(field.Name + " " + field.Tag.Get("json") + " ")
+			writeTypeShape(shape, field.Type, seen)
+			shape.WriteString(";")
+		}
+		shape.WriteString("}")
+		delete(seen, t)
+	default:
+		shape.WriteString(t.Kind().String())
+	}
+}
+
+// resultErr returns the Err field query results report failures in.
+func resultErr(result any) error {
+	value := reflect.Indirect(reflect.ValueOf(result))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	field := value.FieldByName("Err")
+	if !field.IsValid() || field.Kind() != reflect.Interface || field.IsNil() {
+		return nil
+	}
+	err, _ := field.Interface().(error)
+	return err
+}
+
+// encodeCacheEntry writes the entry as JSON, gzipped once it reaches
+// CACHE_COMPRESS_MIN_BYTES.
+func encodeCacheEntry[Result any](entry cacheEntry[Result]) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < config.CACHE_COMPRESS_MIN_BYTES {
+		return body, nil
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// decodeCacheEntry returns ErrIncompatibleCacheEntry for entries written with
+// another schema, or in a format older builds used.
+func decodeCacheEntry[Result any](data []byte) (entry cacheEntry[Result], err error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return entry, err
+		}
+		data, err = io.ReadAll(reader)
+		if err != nil {
+			return entry, err
+		}
+	}
+
+	var header struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return entry, ErrIncompatibleCacheEntry
+	}
+	if header.Schema != resultSchema(reflect.TypeFor[Result]()) {
+		return entry, ErrIncompatibleCacheEntry
+	}
+
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fampay-assignment/config"
+)
+
+type testCachedResult struct {
+	Value string
+	Err   error `json:"-"`
+}
+
+type otherCachedResult struct {
+	Count int64
+}
+
+func TestCacheEntryEncoding(t *testing.T) {
+	schema := resultSchema(reflect.TypeFor[testCachedResult]())
+	freshUntil := time.Date(2024, 11, 14, 17, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		value      string
+		compressed bool
+	}{
+		{
+			name:  "small entries stay plain JSON",
+			value: "small",
+		},
+		{
+			name:       "large entries are gzipped",
+			value:      strings.Repeat("large ", config.CACHE_COMPRESS_MIN_BYTES),
+			compressed: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := cacheEntry[testCachedResult]{
+				Schema:     schema,
+				FreshUntil: freshUntil,
+				Result:     testCachedResult{Value: tc.value},
+			}
+			data, err := encodeCacheEntry(entry)
+			if err != nil {
+				t.Fatalf("encodeCacheEntry() error = %v", err)
+			}
+			if compressed := bytes.HasPrefix(data, gzipMagic); compressed != tc.compressed {
+				t.Errorf("compressed = %v, want %v", compressed, tc.compressed)
+			}
+
+			decoded, err := decodeCacheEntry[testCachedResult](data)
+			if err != nil {
+				t.Fatalf("decodeCacheEntry() error = %v", err)
+			}
+			if decoded.Result.Value != tc.value || !decoded.FreshUntil.Equal(freshUntil) {
+				t.Errorf("decodeCacheEntry() = %+v, want the encoded entry", decoded)
+			}
+		})
+	}
+}
+
+func TestDecodeIncompatibleCacheEntry(t *testing.T) {
+	otherSchema := resultSchema(reflect.TypeFor[otherCachedResult]())
+	encode := func(entry cacheEntry[otherCachedResult]) []byte {
+		data, err := encodeCacheEntry(entry)
+		if err != nil {
+			t.Fatalf("encodeCacheEntry() error = %v", err)
+		}
+		return data
+	}
+	legacy, err := json.Marshal(testCachedResult{Value: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "entry of another result type",
+			data: encode(cacheEntry[otherCachedResult]{Schema: otherSchema}),
+		},
+		{
+			name: "entry of an older schema version",
+			data: encode(cacheEntry[otherCachedResult]{Schema: "0.000000000000"}),
+		},
+		{
+			name: "gzipped entry of another result type",
+			data: encode(cacheEntry[otherCachedResult]{
+				Schema: otherSchema + strings.Repeat(" ", config.CACHE_COMPRESS_MIN_BYTES),
+			}),
+		},
+		{
+			name: "bare result written before entries had a schema",
+			data: legacy,
+		},
+		{
+			name: "not JSON at all",
+			data: []byte("1l_w5g7fbjA"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeCacheEntry[testCachedResult](tc.data)
+			if !errors.Is(err, ErrIncompatibleCacheEntry) {
+				t.Errorf("decodeCacheEntry() error = %v, want ErrIncompatibleCacheEntry", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+
+	"fampay-assignment/connections"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	ChannelRuleAllow = "allow"
+	ChannelRuleBlock = "block"
+
+	channelRuleColumns = `id, list, channel_id, title_pattern, note, created_at`
+)
+
+var (
+	ErrChannelRuleNotFound = errors.New("channel rule not found")
+	ErrChannelRuleExists   = errors.New("channel rule already exists")
+)
+
+func scanChannelRule(row pgx.Row) (rule models.ChannelRule, err error) {
+	err = row.Scan(
+		&rule.ID,
+		&rule.List,
+		&rule.ChannelID,
+		&rule.TitlePattern,
+		&rule.Note,
+		&rule.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = ErrChannelRuleNotFound
+	}
+	return rule, err
+}
+
+// channelMatcher is a rule ready to be checked against a video's channel.
+type channelMatcher struct {
+	channelID    string
+	titlePattern *regexp.Regexp
+}
+
+func (m channelMatcher) matches(channelID string, title string) bool {
+	if m.titlePattern != nil {
+		return m.titlePattern.MatchString(title)
+	}
+	return m.channelID == channelID
+}
+
+// channelRuleSet decides which channels are ingested. Blocked channels never
+// are; once any allow rule exists, only channels matching one are.
+type channelRuleSet struct {
+	allow []channelMatcher
+	block []channelMatcher
+}
+
+func newChannelRuleSet(rules []models.ChannelRule) (set channelRuleSet) {
+	for _, rule := range rules {
+		var matcher channelMatcher
+		switch {
+		case rule.TitlePattern != nil:
+			pattern, err := regexp.Compile(*rule.TitlePattern)
+			if err != nil {
+				logger.Log.WithFields(logger.Fields{
+					"rule":    rule.ID,
+					"pattern": *rule.TitlePattern,
+				}).Warn("skipping channel rule with an invalid pattern")
+				continue
+			}
+			matcher.titlePattern = pattern
+		case rule.ChannelID != nil:
+			matcher.channelID = *rule.ChannelID
+		default:
+			continue
+		}
+
+		if rule.List == ChannelRuleAllow {
+			set.allow = append(set.allow, matcher)
+		} else {
+			set.block = append(set.block, matcher)
+		}
+	}
+	return set
+}
+
+func matchesAny(matchers []channelMatcher, channelID string, title string) bool {
+	for _, matcher := range matchers {
+		if matcher.matches(channelID, title) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s channelRuleSet) blocks(channelID string, title string) bool {
+	return matchesAny(s.block, channelID, title)
+}
+
+func (s channelRuleSet) allows(channelID string, title string) bool {
+	if s.blocks(channelID, title) {
+		return false
+	}
+	return len(s.allow) == 0 || matchesAny(s.allow, channelID, title)
+}
+
+var (
+	channelRulesMu   sync.Mutex
+	lastChannelRules channelRuleSet
+)
+
+// loadChannelRules reads the current rules, falling back to the last set that
+// loaded so a database hiccup does not open the gates mid cycle.
+func loadChannelRules(ctx context.Context, db *pgxpool.Pool) channelRuleSet {
+	channelRulesMu.Lock()
+	defer channelRulesMu.Unlock()
+
+	rules, err := ListChannelRules(ctx, db)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error loading channel rules, using the last loaded set")
+		return lastChannelRules
+	}
+	lastChannelRules = newChannelRuleSet(rules)
+	return lastChannelRules
+}
+
+func ListChannelRules(ctx context.Context, db *pgxpool.Pool) ([]models.ChannelRule, error) {
+	rules := []models.ChannelRule{}
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"ListChannelRules",
+		`SELECT `+channelRuleColumns+`
+		FROM channel_rules
+		ORDER BY id`,
+	)
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanChannelRule(rows)
+		if err != nil {
+			return rules, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// CreateChannelRule stores a rule matching either a channel id or a title
+// pattern; exactly one of them must be set.
+func CreateChannelRule(db *pgxpool.Pool, list string, channelID *string, titlePattern *string, note *string) (models.ChannelRule, error) {
+	rule, err := scanChannelRule(db.QueryRow(
+		connections.GetContext(),
+		`INSERT INTO channel_rules (list, channel_id, title_pattern, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+channelRuleColumns,
+		list,
+		channelID,
+		titlePattern,
+		note,
+	))
+	if isUniqueViolation(err) {
+		err = ErrChannelRuleExists
+	}
+	return rule, err
+}
+
+func DeleteChannelRule(db *pgxpool.Pool, id int) error {
+	tag, err := db.Exec(
+		connections.GetContext(),
+		`DELETE FROM channel_rules WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChannelRuleNotFound
+	}
+	return nil
+}
+
+// PurgeBlockedChannels deletes the stored videos and the channel rows of every
+// channel the block list matches, so they leave the channel listings and stop
+// being enriched. Title patterns are checked against the titles stored with
+// the channels and their videos, in Go, so they behave exactly as they do
+// during ingestion.
+func PurgeBlockedChannels(ctx context.Context, db *pgxpool.Pool) (channels []string, purged int64, err error) {
+	channels = []string{}
+
+	// purging deletes videos, so it must not act on a stale copy of the rules
+	list, err := ListChannelRules(ctx, db)
+	if err != nil {
+		return channels, 0, err
+	}
+	rules := newChannelRuleSet(list)
+	if len(rules.block) == 0 {
+		return channels, 0, nil
+	}
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"ListStoredChannels",
+		`SELECT channel_id, coalesce(channel_title, '')
+		FROM videos
+		UNION
+		SELECT channel_id, title
+		FROM channels`,
+	)
+	if err != nil {
+		return channels, 0, err
+	}
+	defer rows.Close()
+
+	seen := map[string]bool{}
+	for rows.Next() {
+		var channelID, title string
+		if err := rows.Scan(&channelID, &title); err != nil {
+			return channels, 0, err
+		}
+		if !seen[channelID] && rules.blocks(channelID, title) {
+			seen[channelID] = true
+			channels = append(channels, channelID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return channels, 0, err
+	}
+	if len(channels) == 0 {
+		return channels, 0, nil
+	}
+
+	purged, err = executeQuery(
+		db,
+		`DELETE FROM videos WHERE channel_id = ANY($1)`,
+		channels,
+	)
+	if err != nil {
+		return channels, purged, err
+	}
+	purgedChannels, err := executeQuery(
+		db,
+		`DELETE FROM channels WHERE channel_id = ANY($1)`,
+		channels,
+	)
+	if purged > 0 || purgedChannels > 0 {
+		InvalidateCache("")
+	}
+	return channels, purged, err
+}
@@ -0,0 +1,441 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+	"fampay-assignment/tracing"
+	"fampay-assignment/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// channelColumns expects the aggregates of channelStatsJoin as stats
+const channelColumns = `
+	channels.channel_id, channels.title, channels.description,
+	channels.custom_url, channels.thumbnail_url, channels.country,
+	channels.published_at, channels.subscriber_count, channels.video_count,
+	channels.view_count, channels.details_updated_at,
+	stats.stored_videos, stats.latest_video_at,
+	channels.created_at, channels.updated_at`
+
+const channelStatsJoin = `
+	LEFT JOIN LATERAL (
+		SELECT count(*) AS stored_videos, max(videos.published_at) AS latest_video_at
+		FROM videos
+		WHERE videos.channel_id = channels.channel_id
+	) stats ON TRUE`
+
+// ChannelSortColumns maps the sort_by values accepted by the API to columns.
+var ChannelSortColumns = map[string]string{
+	"subscriber_count": "channels.subscriber_count",
+	"title":            "channels.title",
+	"stored_videos":    "stats.stored_videos",
+	"latest_video_at":  "stats.latest_video_at",
+}
+
+var ErrChannelNotFound = errors.New("channel not found")
+
+func scanChannel(row pgx.Row) (channel models.Channel, err error) {
+	err = row.Scan(
+		&channel.ChannelID,
+		&channel.Title,
+		&channel.Description,
+		&channel.CustomURL,
+		&channel.ThumbnailURL,
+		&channel.Country,
+		&channel.PublishedAt,
+		&channel.SubscriberCount,
+		&channel.VideoCount,
+		&channel.ViewCount,
+		&channel.DetailsUpdatedAt,
+		&channel.StoredVideos,
+		&channel.LatestVideoAt,
+		&channel.CreatedAt,
+		&channel.UpdatedAt,
+	)
+	return channel, err
+}
+
+func nilIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// storeChannel records the channel of a newly stored video so it shows up
+// before the enrichment stage has picked it up.
+func storeChannel(db *pgxpool.Pool, channelID string, title string) error {
+	_, err := executeQuery(
+		db,
+		`INSERT INTO channels (channel_id, title)
+		VALUES ($1, $2)
+		ON CONFLICT (channel_id) DO UPDATE
+		SET title = EXCLUDED.title, updated_at = NOW()
+		WHERE channels.title IS DISTINCT FROM EXCLUDED.title`,
+		channelID,
+		title,
+	)
+	return err
+}
+
+// seedChannels records the channels of videos stored before channels were
+// tracked.
+func seedChannels(db *pgxpool.Pool) error {
+	_, err := executeQuery(
+		db,
+		`INSERT INTO channels (channel_id, title)
+		SELECT DISTINCT ON (channel_id) channel_id, coalesce(channel_title, '')
+		FROM videos
+		ORDER BY channel_id, published_at DESC
+		ON CONFLICT (channel_id) DO NOTHING`,
+	)
+	return err
+}
+
+// selectChannelsToEnrich picks up to limit channels for the next
+// channels.list batch: never enriched channels first, then stale ones.
+func selectChannelsToEnrich(ctx context.Context, db *pgxpool.Pool, limit int) ([]string, error) {
+	channelIDs := []string{}
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"SelectChannelsToEnrich",
+		`SELECT channel_id
+		FROM (
+			(
+				SELECT channel_id, 0 AS priority
+				FROM channels
+				WHERE details_updated_at IS NULL
+				LIMIT $1
+			)
+			UNION ALL
+			(
+				SELECT channel_id, 1 AS priority
+				FROM channels
+				WHERE details_updated_at < $2
+				ORDER BY details_updated_at
+				LIMIT $1
+			)
+		) candidates
+		ORDER BY priority
+		LIMIT $1`,
+		limit,
+		time.Now().Add(-config.CHANNEL_REFRESH_INTERVAL),
+	)
+	if err != nil {
+		return channelIDs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channelID string
+		if err := rows.Scan(&channelID); err != nil {
+			return channelIDs, err
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	return channelIDs, rows.Err()
+}
+
+// enrichChannels stores the details of one batch of channels. Channels
+// channels.list no longer returns are stamped so they do not block the queue.
+func enrichChannels(ctx context.Context, db *pgxpool.Pool, channelIDs []string) (enriched int, err error) {
+	ytResponse, err := fetchChannels(ctx, channelIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	missing := map[string]bool{}
+	for _, channelID := range channelIDs {
+		missing[channelID] = true
+	}
+
+	for _, item := range ytResponse.Items {
+		delete(missing, item.ID)
+
+		var subscriberCount *int64
+		if !item.Statistics.HiddenSubscriberCount {
+			subscriberCount = parseCount(item.Statistics.SubscriberCount)
+		}
+		var publishedAt *time.Time
+		if !item.Snippet.PublishedAt.IsZero() {
+			publishedAt = &item.Snippet.PublishedAt
+		}
+
+		_, err := executeQuery(
+			db,
+			`INSERT INTO channels (
+				channel_id, title, description, custom_url, thumbnail_url,
+				country, published_at, subscriber_count, video_count,
+				view_count, details_updated_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+			ON CONFLICT (channel_id) DO UPDATE SET
+				title = EXCLUDED.title,
+				description = EXCLUDED.description,
+				custom_url = EXCLUDED.custom_url,
+				thumbnail_url = EXCLUDED.thumbnail_url,
+				country = EXCLUDED.country,
+				published_at = EXCLUDED.published_at,
+				subscriber_count = EXCLUDED.subscriber_count,
+				video_count = EXCLUDED.video_count,
+				view_count = EXCLUDED.view_count,
+				details_updated_at = NOW(),
+				updated_at = NOW()`,
+			item.ID,
+			item.Snippet.Title,
+			nilIfEmpty(item.Snippet.Description),
+			nilIfEmpty(item.Snippet.CustomURL),
+			nilIfEmpty(item.Snippet.Thumbnails.High.URL),
+			nilIfEmpty(item.Snippet.Country),
+			publishedAt,
+			subscriberCount,
+			parseCount(item.Statistics.VideoCount),
+			parseCount(item.Statistics.ViewCount),
+		)
+		if err != nil {
+			logger.Log.Printf("Failed to enrich channel %s: %v", item.ID, err)
+			continue
+		}
+		enriched++
+	}
+
+	if len(missing) == 0 {
+		return enriched, nil
+	}
+	missingIDs := make([]string, 0, len(missing))
+	for channelID := range missing {
+		missingIDs = append(missingIDs, channelID)
+	}
+	_, err = executeQuery(
+		db,
+		`UPDATE channels SET details_updated_at = NOW() WHERE channel_id = ANY($1)`,
+		missingIDs,
+	)
+	return enriched, err
+}
+
+func runChannelEnrichment(ctx context.Context, db *pgxpool.Pool) {
+	ctx, span := tracing.Start(ctx, "enrich channels")
+	defer span.End()
+
+	for batch := 0; batch < config.ENRICHMENT_MAX_BATCHES_PER_TICK; batch++ {
+		channelIDs, err := selectChannelsToEnrich(ctx, db, config.YOUTUBE_MAX_RESULTS)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error selecting channels to enrich")
+			return
+		}
+		if len(channelIDs) == 0 {
+			return
+		}
+
+		enriched, err := enrichChannels(ctx, db, channelIDs)
+		logger.Log.WithFields(logger.Fields{
+			"requested": len(channelIDs),
+			"enriched":  enriched,
+		}).Info("channel enrichment batch finished")
+		if err != nil {
+			logger.Log.WithError(err).Error("Error in enrichChannels")
+			return
+		}
+		if len(channelIDs) < config.YOUTUBE_MAX_RESULTS {
+			return
+		}
+	}
+}
+
+type ListChannelsQueryParams struct {
+	PaginationPage int
+	PaginationSize int
+	SortOrder      string
+	SortBy         string
+	// case insensitive substring of the channel title
+	Title string
+}
+
+type ListChannelsQueryResult struct {
+	Channels []models.Channel
+	HasMore  bool
+	Err      error
+}
+
+func listChannelsQuery(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *ListChannelsQueryParams,
+) (response ListChannelsQueryResult) {
+	response.Channels = []models.Channel{}
+
+	where := &whereBuilder{}
+	if params.Title != "" {
+		where.and("channels.title ILIKE %s", containsPattern(params.Title))
+	}
+	query := fmt.Sprintf(
+		`SELECT
+			`+channelColumns+`
+		FROM
+			channels
+			`+channelStatsJoin+`
+		WHERE
+			%s
+		ORDER BY
+			%s %s NULLS LAST, channels.channel_id
+		LIMIT %s OFFSET %s`,
+		where.sql(),
+		ChannelSortColumns[params.SortBy],
+		params.SortOrder,
+		where.arg(params.PaginationSize+1),
+		where.arg(utils.GetPaginationOffset(params.PaginationPage, params.PaginationSize)),
+	)
+
+	rows, err := executePostgresQuery(ctx, db, "ListChannelsQuery", query, where.args...)
+	if err != nil {
+		response.Err = err
+		return response
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			response.Err = err
+			return response
+		}
+		response.Channels = append(response.Channels, channel)
+	}
+	response.Err = rows.Err()
+
+	if len(response.Channels) > params.PaginationSize {
+		response.HasMore = true
+		response.Channels = response.Channels[:params.PaginationSize]
+	}
+	return response
+}
+
+func listChannelsQueryCached(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *ListChannelsQueryParams,
+) (response ListChannelsQueryResult) {
+	return cacheQuery(
+		ctx,
+		"ListChannelsQuery",
+		listChannelsQuery,
+		db,
+		params,
+	)
+}
+
+func listChannelsQueryAsync(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *ListChannelsQueryParams,
+	ch chan<- ListChannelsQueryResult,
+) {
+	ch <- listChannelsQueryCached(ctx, db, params)
+}
+
+func ListChannels(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *ListChannelsQueryParams,
+) (response ListChannelsQueryResult) {
+	channelsChan := make(chan ListChannelsQueryResult, 1)
+	go listChannelsQueryAsync(ctx, db, params, channelsChan)
+
+	select {
+	case response = <-channelsChan:
+	case <-time.After(2 * time.Second):
+		response.Err = fmt.Errorf("ListChannels timed out")
+	}
+
+	return response
+}
+
+type GetChannelQueryParams struct {
+	ChannelID string
+}
+
+type GetChannelQueryResult struct {
+	Channel models.Channel
+	Found   bool
+	Err     error
+}
+
+func getChannelQuery(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetChannelQueryParams,
+) (response GetChannelQueryResult) {
+	channel, err := scanChannel(executePostgresQueryRow(
+		ctx,
+		db,
+		"GetChannel",
+		`SELECT
+			`+channelColumns+`
+		FROM
+			channels
+			`+channelStatsJoin+`
+		WHERE
+			channels.channel_id = $1`,
+		params.ChannelID,
+	))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		response.Err = err
+	default:
+		response.Channel = channel
+		response.Found = true
+	}
+	return response
+}
+
+func getChannelQueryCached(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetChannelQueryParams,
+) (response GetChannelQueryResult) {
+	return cacheQuery(
+		ctx,
+		"GetChannelQuery",
+		getChannelQuery,
+		db,
+		params,
+	)
+}
+
+func getChannelQueryAsync(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetChannelQueryParams,
+	ch chan<- GetChannelQueryResult,
+) {
+	ch <- getChannelQueryCached(ctx, db, params)
+}
+
+func GetChannel(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetChannelQueryParams,
+) (response GetChannelQueryResult) {
+	channelChan := make(chan GetChannelQueryResult, 1)
+	go getChannelQueryAsync(ctx, db, params, channelChan)
+
+	select {
+	case response = <-channelChan:
+	case <-time.After(2 * time.Second):
+		response.Err = fmt.Errorf("GetChannel timed out")
+	}
+
+	return response
+}
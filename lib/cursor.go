@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"fampay-assignment/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// VideoCursor is a position in the (published_at, video_id) order of videos.
+// Backward cursors page towards the start of the list, forward cursors
+// towards its end; SortOrder pins the cursor to the order it was issued for.
+type VideoCursor struct {
+	PublishedAt time.Time `json:"p"`
+	VideoID     string    `json:"v"`
+	SortOrder   string    `json:"o"`
+	Backward    bool      `json:"b,omitempty"`
+}
+
+func NewVideoCursor(video models.Video, sortOrder string, backward bool) VideoCursor {
+	return VideoCursor{
+		PublishedAt: video.PublishedAt,
+		VideoID:     video.VideoID,
+		SortOrder:   sortOrder,
+		Backward:    backward,
+	}
+}
+
+// Encode returns the opaque token handed out to clients.
+func (c VideoCursor) Encode() string {
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func DecodeVideoCursor(token string) (cursor VideoCursor, err error) {
+	body, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(body, &cursor); err != nil || cursor.VideoID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.SortOrder != "asc" && cursor.SortOrder != "desc" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
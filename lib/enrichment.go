@@ -0,0 +1,212 @@
+package lib
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/connections"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+	"fampay-assignment/tracing"
+	"fampay-assignment/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// selectVideosToEnrich picks up to limit videos for the next videos.list
+// batch: never enriched videos first, then recent videos with stale statistics.
+func selectVideosToEnrich(ctx context.Context, db *pgxpool.Pool, limit int) ([]string, error) {
+	videoIDs := []string{}
+
+	now := time.Now()
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"SelectVideosToEnrich",
+		`SELECT video_id
+		FROM (
+			(
+				SELECT video_id, 0 AS priority
+				FROM videos
+				WHERE stats_updated_at IS NULL
+				ORDER BY published_at DESC
+				LIMIT $1
+			)
+			UNION ALL
+			(
+				SELECT video_id, 1 AS priority
+				FROM videos
+				WHERE published_at > $2 AND stats_updated_at < $3
+				ORDER BY stats_updated_at
+				LIMIT $1
+			)
+		) candidates
+		ORDER BY priority
+		LIMIT $1`,
+		limit,
+		now.Add(-config.STATS_REFRESH_WINDOW),
+		now.Add(-config.STATS_REFRESH_INTERVAL),
+	)
+	if err != nil {
+		return videoIDs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var videoID string
+		if err := rows.Scan(&videoID); err != nil {
+			return videoIDs, err
+		}
+		videoIDs = append(videoIDs, videoID)
+	}
+	return videoIDs, rows.Err()
+}
+
+func parseCount(value string) *int64 {
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &count
+}
+
+// applyVideoDetails copies the statistics and content details of a
+// videos.list item onto the video.
+func applyVideoDetails(video *models.Video, item YouTubeVideo) {
+	video.ViewCount = parseCount(item.Statistics.ViewCount)
+	video.LikeCount = parseCount(item.Statistics.LikeCount)
+	video.CommentCount = parseCount(item.Statistics.CommentCount)
+	video.DurationSeconds = nil
+	if duration, err := utils.ParseISO8601Duration(item.ContentDetails.Duration); err == nil {
+		seconds := int(duration.Seconds())
+		video.DurationSeconds = &seconds
+	}
+	video.Definition = nil
+	if item.ContentDetails.Definition != "" {
+		definition := item.ContentDetails.Definition
+		video.Definition = &definition
+	}
+	hasCaptions := item.ContentDetails.Caption == "true"
+	video.HasCaptions = &hasCaptions
+}
+
+// enrichVideos fetches statistics and content details for one batch of video
+// ids and stores them. Ids videos.list no longer returns (deleted or private
+// videos) are stamped as well so they do not block the queue.
+func enrichVideos(ctx context.Context, db *pgxpool.Pool, videoIDs []string) (enriched int, err error) {
+	ytResponse, err := fetchVideoDetails(ctx, videoIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	missing := map[string]bool{}
+	for _, videoID := range videoIDs {
+		missing[videoID] = true
+	}
+
+	for _, item := range ytResponse.Items {
+		delete(missing, item.ID)
+
+		var video models.Video
+		applyVideoDetails(&video, item)
+		_, err := executeQuery(
+			db,
+			`UPDATE videos
+			SET
+				view_count = $2,
+				like_count = $3,
+				comment_count = $4,
+				duration_seconds = $5,
+				definition = $6,
+				has_captions = $7,
+				stats_updated_at = NOW()
+			WHERE video_id = $1`,
+			item.ID,
+			video.ViewCount,
+			video.LikeCount,
+			video.CommentCount,
+			video.DurationSeconds,
+			video.Definition,
+			video.HasCaptions,
+		)
+		if err != nil {
+			logger.Log.Printf("Failed to enrich video %s: %v", item.ID, err)
+			continue
+		}
+		enriched++
+	}
+
+	if len(missing) == 0 {
+		return enriched, nil
+	}
+	missingIDs := make([]string, 0, len(missing))
+	for videoID := range missing {
+		missingIDs = append(missingIDs, videoID)
+	}
+	_, err = executeQuery(
+		db,
+		`UPDATE videos SET stats_updated_at = NOW() WHERE video_id = ANY($1)`,
+		missingIDs,
+	)
+	return enriched, err
+}
+
+func runEnrichment(ctx context.Context, db *pgxpool.Pool) {
+	ctx, span := tracing.Start(ctx, "enrich videos")
+	defer span.End()
+
+	for batch := 0; batch < config.ENRICHMENT_MAX_BATCHES_PER_TICK; batch++ {
+		videoIDs, err := selectVideosToEnrich(ctx, db, config.YOUTUBE_MAX_RESULTS)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error selecting videos to enrich")
+			return
+		}
+		if len(videoIDs) == 0 {
+			return
+		}
+
+		enriched, err := enrichVideos(ctx, db, videoIDs)
+		logger.Log.WithFields(logger.Fields{
+			"requested": len(videoIDs),
+			"enriched":  enriched,
+		}).Info("enrichment batch finished")
+		if err != nil {
+			logger.Log.WithError(err).Error("Error in enrichVideos")
+			return
+		}
+		if len(videoIDs) < config.YOUTUBE_MAX_RESULTS {
+			return
+		}
+	}
+}
+
+// StartEnrichingVideos periodically enriches newly inserted videos and
+// refreshes the statistics of recent ones through batched videos.list calls,
+// then does the same for channels through channels.list.
+func StartEnrichingVideos(ctx context.Context) {
+	db, ok := connections.GetPostgresDb()
+	if !ok {
+		logger.Log.Fatal("Error connecting to database")
+		return
+	}
+
+	if err := seedChannels(db); err != nil {
+		logger.Log.WithError(err).Error("Error seeding channels")
+	}
+
+	ticker := time.NewTicker(config.ENRICHMENT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Info("Stopping video enrichment service...")
+			return
+		case <-ticker.C:
+			runEnrichment(ctx, db)
+			runChannelEnrichment(ctx, db)
+		}
+	}
+}
@@ -31,6 +31,30 @@ func (e *ExternalError) BadRequest(message string) ExternalError {
 	return *e
 }
 
+func (e *ExternalError) Unauthorized(message string) ExternalError {
+	e.Code = http.StatusUnauthorized
+	e.Type = "unauthorized"
+
+	if message == "" {
+		e.Message = "authentication required"
+	} else {
+		e.Message = message
+	}
+	return *e
+}
+
+func (e *ExternalError) Forbidden(message string) ExternalError {
+	e.Code = http.StatusForbidden
+	e.Type = "forbidden"
+
+	if message == "" {
+		e.Message = "forbidden"
+	} else {
+		e.Message = message
+	}
+	return *e
+}
+
 func (e *ExternalError) NotFound(message string) ExternalError {
 	e.Code = http.StatusNotFound
 	e.Type = "not_found"
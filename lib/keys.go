@@ -0,0 +1,554 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"fampay-assignment/config"
+	"fampay-assignment/connections"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const youTubeAPIKeyColumns = `
+	id, key, state, exhausted_until, last_error, last_error_at,
+	usage_count, error_count, last_used_at, created_at, updated_at`
+
+var (
+	ErrNoAPIKeys      = errors.New("no API keys available")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// YouTube quotas reset at midnight Pacific time
+	quotaResetLocation = mustLoadLocation("America/Los_Angeles")
+
+	ApiKeys = NewAPIKeys()
+)
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"location": name,
+			"err":      err,
+		}).Fatal("failed to load time zone")
+	}
+	return location
+}
+
+// nextQuotaReset returns the next midnight in Pacific time after now.
+func nextQuotaReset(now time.Time) time.Time {
+	local := now.In(quotaResetLocation)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, quotaResetLocation)
+}
+
+// APIKeys is the pool of YouTube API keys shared by the fetcher, the
+// enrichment stage, backfills and the key endpoints. All state lives behind
+// the mutex and every state change is written through to youtube_api_keys.
+type APIKeys struct {
+	mu      sync.Mutex
+	db      *pgxpool.Pool
+	keys    []*models.YouTubeAPIKey
+	currKey int
+
+	// estimated quota units and calls per key on usageDay (Pacific time)
+	usageDay   string
+	unitsToday map[string]int
+	callsToday map[string]int
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{
+		unitsToday: map[string]int{},
+		callsToday: map[string]int{},
+	}
+}
+
+func scanYouTubeAPIKey(row pgx.Row) (key models.YouTubeAPIKey, err error) {
+	err = row.Scan(
+		&key.ID,
+		&key.Key,
+		&key.State,
+		&key.ExhaustedUntil,
+		&key.LastError,
+		&key.LastErrorAt,
+		&key.UsageCount,
+		&key.ErrorCount,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	return key, err
+}
+
+// load seeds the table with the configured keys and loads every stored key.
+// Without the table the pool falls back to the configured keys in memory.
+func (k *APIKeys) load(db *pgxpool.Pool, seedKeys []string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.db = nil
+	k.keys = []*models.YouTubeAPIKey{}
+	for _, seedKey := range seedKeys {
+		if seedKey != "" {
+			k.keys = append(k.keys, &models.YouTubeAPIKey{Key: seedKey, State: models.KeyActive})
+		}
+	}
+
+	for _, seedKey := range seedKeys {
+		if seedKey == "" {
+			continue
+		}
+		_, err := executeQuery(
+			db,
+			`INSERT INTO youtube_api_keys (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`,
+			seedKey,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := executePostgresQuery(
+		context.Background(),
+		db,
+		"LoadYouTubeAPIKeys",
+		`SELECT `+youTubeAPIKeyColumns+` FROM youtube_api_keys ORDER BY id`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	keys := []*models.YouTubeAPIKey{}
+	for rows.Next() {
+		key, err := scanYouTubeAPIKey(rows)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	k.db = db
+	k.keys = keys
+	return k.loadUsageLocked(time.Now())
+}
+
+// refreshLocked brings quota exhausted keys back once their quota has reset.
+func (k *APIKeys) refreshLocked(now time.Time) (changed []models.YouTubeAPIKey) {
+	for _, key := range k.keys {
+		if key.State == models.KeyQuotaExhausted && key.ExhaustedUntil != nil && !now.Before(*key.ExhaustedUntil) {
+			key.State = models.KeyActive
+			key.ExhaustedUntil = nil
+			changed = append(changed, *key)
+		}
+	}
+	return changed
+}
+
+func (k *APIKeys) findLocked(value string) *models.YouTubeAPIKey {
+	for _, key := range k.keys {
+		if key.Key == value {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *APIKeys) findByIDLocked(id int) *models.YouTubeAPIKey {
+	for _, key := range k.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// persistAPIKeys writes key states through to the database. It is called
+// after the pool's mutex has been released so slow writes never block key
+// selection.
+func persistAPIKeys(db *pgxpool.Pool, keys ...models.YouTubeAPIKey) {
+	if db == nil {
+		return
+	}
+	for _, key := range keys {
+		_, err := executeQuery(
+			db,
+			`UPDATE youtube_api_keys
+			SET
+				state = $2,
+				exhausted_until = $3,
+				last_error = $4,
+				last_error_at = $5,
+				error_count = $6,
+				updated_at = NOW()
+			WHERE key = $1`,
+			key.Key,
+			key.State,
+			key.ExhaustedUntil,
+			key.LastError,
+			key.LastErrorAt,
+			key.ErrorCount,
+		)
+		if err != nil {
+			logger.Log.WithFields(logger.Fields{
+				"key": key.ID,
+				"err": err,
+			}).Error("failed to persist api key state")
+		}
+	}
+}
+
+// Acquire returns the next active key, rotating round robin from the key
+// that was used last. Keys whose estimated usage already reached
+// YOUTUBE_DAILY_QUOTA are skipped, so every caller stops spending on them
+// before YouTube starts answering quotaExceeded.
+func (k *APIKeys) Acquire() (string, error) {
+	now := time.Now()
+	k.mu.Lock()
+	db := k.db
+	changed := k.refreshLocked(now)
+	k.rollUsageDayLocked(now)
+	value, err := "", ErrNoAPIKeys
+	for i := 1; i <= len(k.keys); i++ {
+		index := (k.currKey + i) % len(k.keys)
+		if k.remainingLocked(k.keys[index]) > 0 {
+			k.currKey = index
+			value, err = k.keys[index].Key, nil
+			break
+		}
+	}
+	k.mu.Unlock()
+
+	persistAPIKeys(db, changed...)
+	return value, err
+}
+
+// ReportSuccess counts a successful call made with the key.
+func (k *APIKeys) ReportSuccess(value string) {
+	k.mu.Lock()
+	db := k.db
+	key := k.findLocked(value)
+	if key != nil {
+		now := time.Now()
+		key.UsageCount++
+		key.LastUsedAt = &now
+	}
+	k.mu.Unlock()
+
+	if key == nil || db == nil {
+		return
+	}
+	_, err := executeQuery(
+		db,
+		`UPDATE youtube_api_keys
+		SET usage_count = usage_count + 1, last_used_at = NOW()
+		WHERE key = $1`,
+		value,
+	)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to record api key usage")
+	}
+}
+
+// report records the failure and, unless state is empty, moves the key into
+// state. Disabled keys stay disabled. Acquire rotates on every call, so a
+// failing key is only tried again after every other active key.
+func (k *APIKeys) report(value string, state string, exhaustedUntil *time.Time, reason string) {
+	k.mu.Lock()
+	db := k.db
+	key := k.findLocked(value)
+	var changed models.YouTubeAPIKey
+	if key != nil {
+		now := time.Now()
+		if state != "" && key.State != models.KeyDisabled {
+			key.State = state
+			key.ExhaustedUntil = exhaustedUntil
+		}
+		key.LastError = &reason
+		key.LastErrorAt = &now
+		key.ErrorCount++
+		changed = *key
+	}
+	k.mu.Unlock()
+
+	if key == nil {
+		return
+	}
+	logger.Log.WithFields(logger.Fields{
+		"key":    changed.ID,
+		"state":  changed.State,
+		"reason": reason,
+	}).Warn("api key reported")
+	persistAPIKeys(db, changed)
+}
+
+// ReportQuotaExceeded parks the key until the next Pacific midnight.
+func (k *APIKeys) ReportQuotaExceeded(value string, reason string) {
+	resetAt := nextQuotaReset(time.Now())
+	k.report(value, models.KeyQuotaExhausted, &resetAt, reason)
+}
+
+// ReportInvalid takes a revoked or misconfigured key out of rotation for good.
+func (k *APIKeys) ReportInvalid(value string, reason string) {
+	k.report(value, models.KeyInvalid, nil, reason)
+}
+
+// ReportError records a transient failure and leaves the key's state alone,
+// so it neither parks an active key nor revives an exhausted or invalid one.
+func (k *APIKeys) ReportError(value string, reason string) {
+	k.report(value, "", nil, reason)
+}
+
+// Add registers a new key, returning the stored key when it is already known.
+func (k *APIKeys) Add(value string) (models.YouTubeAPIKey, error) {
+	if value == "" {
+		return models.YouTubeAPIKey{}, errors.New("new API key is empty")
+	}
+
+	k.mu.Lock()
+	db := k.db
+	if key := k.findLocked(value); key != nil {
+		k.mu.Unlock()
+		return *key, nil
+	}
+	k.mu.Unlock()
+
+	key := models.YouTubeAPIKey{Key: value, State: models.KeyActive}
+	if db != nil {
+		var err error
+		key, err = scanYouTubeAPIKey(db.QueryRow(
+			connections.GetContext(),
+			`INSERT INTO youtube_api_keys (key)
+			VALUES ($1)
+			ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+			RETURNING `+youTubeAPIKeyColumns,
+			value,
+		))
+		if err != nil {
+			return key, err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// a concurrent Add may have registered the key while it was stored
+	if existing := k.findLocked(value); existing != nil {
+		return *existing, nil
+	}
+	k.keys = append(k.keys, &key)
+	return key, nil
+}
+
+// ActiveCount returns how many keys can currently be used.
+func (k *APIKeys) ActiveCount() int {
+	k.mu.Lock()
+	db := k.db
+	changed := k.refreshLocked(time.Now())
+	count := 0
+	for _, key := range k.keys {
+		if key.State == models.KeyActive {
+			count++
+		}
+	}
+	k.mu.Unlock()
+
+	persistAPIKeys(db, changed...)
+	return count
+}
+
+// UsableCount returns how many keys are neither invalid nor disabled, i.e.
+// will have quota available at some point today or after the next reset.
+func (k *APIKeys) UsableCount() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	count := 0
+	for _, key := range k.keys {
+		if key.State != models.KeyInvalid && key.State != models.KeyDisabled {
+			count++
+		}
+	}
+	return count
+}
+
+// CountByState returns how many keys are in each state, every state included.
+func (k *APIKeys) CountByState() map[string]int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	counts := map[string]int{
+		models.KeyActive:         0,
+		models.KeyQuotaExhausted: 0,
+		models.KeyInvalid:        0,
+		models.KeyDisabled:       0,
+	}
+	for _, key := range k.keys {
+		counts[key.State]++
+	}
+	return counts
+}
+
+// Get returns the key with today's usage.
+func (k *APIKeys) Get(id int) (KeyQuotaUsage, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.rollUsageDayLocked(time.Now())
+	key := k.findByIDLocked(id)
+	if key == nil {
+		return KeyQuotaUsage{}, ErrAPIKeyNotFound
+	}
+	return k.keyUsageLocked(key), nil
+}
+
+// SetEnabled takes the key out of rotation or puts it back. An enabled key
+// returns to quota_exhausted when its quota has not reset yet; invalid keys
+// are enabled as well so operators can recover a key after fixing it.
+func (k *APIKeys) SetEnabled(id int, enabled bool) (KeyQuotaUsage, error) {
+	now := time.Now()
+
+	k.mu.Lock()
+	db := k.db
+	k.rollUsageDayLocked(now)
+	key := k.findByIDLocked(id)
+	if key == nil {
+		k.mu.Unlock()
+		return KeyQuotaUsage{}, ErrAPIKeyNotFound
+	}
+	switch {
+	case !enabled:
+		key.State = models.KeyDisabled
+	case key.ExhaustedUntil != nil && now.Before(*key.ExhaustedUntil):
+		key.State = models.KeyQuotaExhausted
+	default:
+		key.State = models.KeyActive
+		key.ExhaustedUntil = nil
+	}
+	changed := *key
+	usage := k.keyUsageLocked(key)
+	k.mu.Unlock()
+
+	persistAPIKeys(db, changed)
+	return usage, nil
+}
+
+// Remove deletes the key together with its usage history. Keys that are still
+// configured through YOUTUBE_API_KEY1..3 are seeded again on the next start.
+func (k *APIKeys) Remove(id int) error {
+	k.mu.Lock()
+	db := k.db
+	found := k.findByIDLocked(id) != nil
+	k.mu.Unlock()
+	if !found {
+		return ErrAPIKeyNotFound
+	}
+
+	// the delete runs outside the lock so key selection never waits on it
+	if db != nil {
+		_, err := executeQuery(db, `DELETE FROM youtube_api_keys WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// a concurrent Remove may have spliced the key out meanwhile
+	index := -1
+	for i, key := range k.keys {
+		if key.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	value := k.keys[index].Key
+	k.keys = append(k.keys[:index], k.keys[index+1:]...)
+	delete(k.unitsToday, value)
+	delete(k.callsToday, value)
+	if k.currKey > index {
+		k.currKey--
+	}
+	if k.currKey >= len(k.keys) {
+		k.currKey = 0
+	}
+	return nil
+}
+
+type APIKeyTestResult struct {
+	Key     KeyQuotaUsage
+	Passed  bool
+	Error   string
+	Latency time.Duration
+}
+
+// TestAPIKey spends one quota unit on an i18nRegions.list call made with the
+// key, which updates the key's state like any other call. An invalid key that
+// passes the test is put back into rotation.
+func TestAPIKey(ctx context.Context, id int) (result APIKeyTestResult, err error) {
+	result.Key, err = ApiKeys.Get(id)
+	if err != nil {
+		return result, err
+	}
+
+	var response struct{}
+	query := url.Values{}
+	query.Set("part", "snippet")
+	start := time.Now()
+	callErr := callYouTubeAPIWithKey(ctx, result.Key.Key.Key, "i18nRegions", config.YOUTUBE_KEY_TEST_COST, query, &response)
+	result.Latency = time.Since(start)
+	result.Passed = callErr == nil
+	if callErr != nil {
+		result.Error = callErr.Error()
+	}
+
+	if result.Passed && result.Key.Key.State == models.KeyInvalid {
+		result.Key, err = ApiKeys.SetEnabled(id, true)
+	} else {
+		result.Key, err = ApiKeys.Get(id)
+	}
+	return result, err
+}
+
+// AddKey registers a new key and returns it with today's usage.
+func AddKey(newKey string) (KeyQuotaUsage, error) {
+	key, err := ApiKeys.Add(newKey)
+	if err != nil {
+		return KeyQuotaUsage{}, err
+	}
+
+	ApiKeys.mu.Lock()
+	defer ApiKeys.mu.Unlock()
+	return ApiKeys.keyUsageLocked(&key), nil
+}
+
+// LoadApiKeys fills the pool from the database and the keys configured
+// through the environment. It needs connections.Connect to have run.
+func LoadApiKeys() {
+	db, ok := connections.GetPostgresDb()
+	if !ok {
+		logger.Log.Fatal("failed to load api keys: postgres connection not found")
+	}
+
+	err := ApiKeys.load(db, []string{
+		config.YoutubeApiKey1,
+		config.YoutubeApiKey2,
+		config.YoutubeApiKey3,
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to load api keys, using configured keys only")
+	}
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"fampay-assignment/models"
+)
+
+func TestReportKeepsState(t *testing.T) {
+	resetAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name           string
+		state          string
+		exhaustedUntil *time.Time
+		report         func(k *APIKeys)
+		wantState      string
+		wantExhausted  bool
+	}{
+		{
+			name:      "errors keep an active key active",
+			state:     models.KeyActive,
+			report:    func(k *APIKeys) { k.ReportError("key", "backendError") },
+			wantState: models.KeyActive,
+		},
+		{
+			name:           "errors do not revive an exhausted key",
+			state:          models.KeyQuotaExhausted,
+			exhaustedUntil: &resetAt,
+			report:         func(k *APIKeys) { k.ReportError("key", "backendError") },
+			wantState:      models.KeyQuotaExhausted,
+			wantExhausted:  true,
+		},
+		{
+			name:      "errors do not revive an invalid key",
+			state:     models.KeyInvalid,
+			report:    func(k *APIKeys) { k.ReportError("key", "backendError") },
+			wantState: models.KeyInvalid,
+		},
+		{
+			name:      "errors keep a disabled key disabled",
+			state:     models.KeyDisabled,
+			report:    func(k *APIKeys) { k.ReportError("key", "backendError") },
+			wantState: models.KeyDisabled,
+		},
+		{
+			name:          "exceeded quota parks an active key",
+			state:         models.KeyActive,
+			report:        func(k *APIKeys) { k.ReportQuotaExceeded("key", "quotaExceeded") },
+			wantState:     models.KeyQuotaExhausted,
+			wantExhausted: true,
+		},
+		{
+			name:      "invalid keys leave rotation",
+			state:     models.KeyActive,
+			report:    func(k *APIKeys) { k.ReportInvalid("key", "keyInvalid") },
+			wantState: models.KeyInvalid,
+		},
+		{
+			name:      "reports keep a disabled key disabled",
+			state:     models.KeyDisabled,
+			report:    func(k *APIKeys) { k.ReportInvalid("key", "keyInvalid") },
+			wantState: models.KeyDisabled,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			k := NewAPIKeys()
+			key := &models.YouTubeAPIKey{Key: "key", State: tc.state, ExhaustedUntil: tc.exhaustedUntil}
+			k.keys = []*models.YouTubeAPIKey{key}
+
+			tc.report(k)
+
+			if key.State != tc.wantState {
+				t.Errorf("state = %q, want %q", key.State, tc.wantState)
+			}
+			if exhausted := key.ExhaustedUntil != nil; exhausted != tc.wantExhausted {
+				t.Errorf("exhausted until set = %v, want %v", exhausted, tc.wantExhausted)
+			}
+			if key.ErrorCount != 1 || key.LastError == nil {
+				t.Errorf("error count = %d, last error = %v, want the failure recorded", key.ErrorCount, key.LastError)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		remove   int
+		wantErr  error
+		wantKeys []string
+		wantCurr int
+	}{
+		{
+			name:     "splices the key out",
+			remove:   2,
+			wantKeys: []string{"a", "c"},
+			wantCurr: 1,
+		},
+		{
+			name:     "keeps rotating from the same key",
+			remove:   1,
+			wantKeys: []string{"b", "c"},
+			wantCurr: 1,
+		},
+		{
+			name:     "wraps around when the last key goes",
+			remove:   3,
+			wantKeys: []string{"a", "b"},
+			wantCurr: 0,
+		},
+		{
+			name:     "unknown ids are not found",
+			remove:   4,
+			wantErr:  ErrAPIKeyNotFound,
+			wantKeys: []string{"a", "b", "c"},
+			wantCurr: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			k := NewAPIKeys()
+			for i, value := range []string{"a", "b", "c"} {
+				k.keys = append(k.keys, &models.YouTubeAPIKey{ID: i + 1, Key: value, State: models.KeyActive})
+			}
+			k.currKey = 2
+
+			if err := k.Remove(tc.remove); err != tc.wantErr {
+				t.Fatalf("Remove() error = %v, want %v", err, tc.wantErr)
+			}
+			keys := []string{}
+			for _, key := range k.keys {
+				keys = append(keys, key.Key)
+			}
+			if !slices.Equal(keys, tc.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tc.wantKeys)
+			}
+			if k.currKey != tc.wantCurr {
+				t.Errorf("currKey = %d, want %d", k.currKey, tc.wantCurr)
+			}
+		})
+	}
+}
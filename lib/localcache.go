@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type localCacheItem struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+// localCache is a bounded LRU kept in process. cacheQuery puts one in front
+// of the cache backend for decoded results and tag versions, and the memory
+// backend keeps its entries in another. A nil *localCache is a disabled
+// layer: it never hits and ignores writes.
+type localCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	items      map[string]*list.Element
+	// most recently used at the front
+	order *list.List
+}
+
+func newLocalCache(maxEntries int, ttl time.Duration) *localCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &localCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		items:      map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// queryLocalCache stays disabled until SetupQueryCache runs.
+var queryLocalCache *localCache
+
+func (c *localCache) enabled() bool {
+	return c != nil
+}
+
+func (c *localCache) get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*localCacheItem)
+	if time.Now().After(item.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return item.value, true
+}
+
+func (c *localCache) set(key string, value any) {
+	if c == nil {
+		return
+	}
+	c.setFor(key, value, c.ttl)
+}
+
+// setFor stores the value for ttl instead of the cache's own lifetime.
+func (c *localCache) setFor(key string, value any, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		item := element.Value.(*localCacheItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&localCacheItem{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*localCacheItem).key)
+	}
+}
+
+func (c *localCache) delete(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// each calls fn for every item that has not expired.
+func (c *localCache) each(fn func(key string, value any)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		item := element.Value.(*localCacheItem)
+		if now.Before(item.expiresAt) {
+			fn(item.key, item.value)
+		}
+	}
+}
+
+func (c *localCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *localCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		ttl        time.Duration
+		run        func(c *localCache)
+		present    []string
+		missing    []string
+	}{
+		{
+			name:       "keeps entries within its ttl",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+			},
+			present: []string{"a"},
+		},
+		{
+			name:       "drops expired entries",
+			maxEntries: 2,
+			ttl:        -time.Second,
+			run: func(c *localCache) {
+				c.set("a", 1)
+			},
+			missing: []string{"a"},
+		},
+		{
+			name:       "evicts the least recently used entry",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+				c.set("b", 2)
+				c.get("a")
+				c.set("c", 3)
+			},
+			present: []string{"a", "c"},
+			missing: []string{"b"},
+		},
+		{
+			name:       "overwriting an entry refreshes it",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+				c.set("b", 2)
+				c.set("a", 3)
+				c.set("c", 4)
+			},
+			present: []string{"a", "c"},
+			missing: []string{"b"},
+		},
+		{
+			name:       "deletes entries",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+				c.set("b", 2)
+				c.delete("a")
+			},
+			present: []string{"b"},
+			missing: []string{"a"},
+		},
+		{
+			name:       "expires entries set for a shorter ttl",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+				c.setFor("b", 2, -time.Second)
+			},
+			present: []string{"a"},
+			missing: []string{"b"},
+		},
+		{
+			name:       "purges every entry",
+			maxEntries: 2,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+				c.set("b", 2)
+				c.purge()
+			},
+			missing: []string{"a", "b"},
+		},
+		{
+			name:       "is disabled without entries",
+			maxEntries: 0,
+			ttl:        time.Minute,
+			run: func(c *localCache) {
+				c.set("a", 1)
+			},
+			missing: []string{"a"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newLocalCache(tc.maxEntries, tc.ttl)
+			tc.run(c)
+
+			for _, key := range tc.present {
+				if _, ok := c.get(key); !ok {
+					t.Errorf("get(%q) missed, want a hit", key)
+				}
+			}
+			for _, key := range tc.missing {
+				if value, ok := c.get(key); ok {
+					t.Errorf("get(%q) = %v, want a miss", key, value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"fampay-assignment/config"
+)
+
+// memoryCache keeps entries in process, for running without Redis. Entries
+// are not shared between instances and do not survive restarts.
+type memoryCache struct {
+	entries *localCache
+
+	mu       sync.Mutex
+	versions map[string]int64
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		entries:  newLocalCache(config.MEMORY_CACHE_MAX_ENTRIES, config.CACHE_TTL),
+		versions: map[string]int64{},
+	}
+}
+
+func (c *memoryCache) Name() string {
+	return config.CACHE_BACKEND_MEMORY
+}
+
+func (c *memoryCache) Get(key string) ([]byte, error) {
+	value, ok := c.entries.get(key)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return value.([]byte), nil
+}
+
+func (c *memoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	c.entries.setFor(key, value, ttl)
+	return nil
+}
+
+func (c *memoryCache) Delete(key string) error {
+	c.entries.delete(key)
+	return nil
+}
+
+func (c *memoryCache) TagVersions(tags ...string) ([]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	versions := make([]int64, len(tags))
+	for i, tag := range tags {
+		versions[i] = c.versions[tag]
+	}
+	return versions, nil
+}
+
+func (c *memoryCache) InvalidateTag(tag string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.versions[tag]++
+	return c.versions[tag], nil
+}
+
+func (c *memoryCache) Usage(prefix string) (usage CacheUsage, err error) {
+	c.entries.each(func(key string, value any) {
+		if strings.HasPrefix(key, prefix) {
+			usage.Keys++
+			usage.Bytes += int64(len(value.([]byte)))
+		}
+	})
+	return usage, nil
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestMemoryCache(maxEntries int) *memoryCache {
+	return &memoryCache{
+		entries:  newLocalCache(maxEntries, time.Minute),
+		versions: map[string]int64{},
+	}
+}
+
+func TestMemoryCacheEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(c *memoryCache)
+		want    map[string]string
+		missing []string
+	}{
+		{
+			name: "returns what was set",
+			run: func(c *memoryCache) {
+				c.Set("a", []byte("1"), time.Minute)
+			},
+			want: map[string]string{"a": "1"},
+		},
+		{
+			name:    "misses keys never set",
+			run:     func(c *memoryCache) {},
+			want:    map[string]string{},
+			missing: []string{"a"},
+		},
+		{
+			name: "expires entries after their ttl",
+			run: func(c *memoryCache) {
+				c.Set("a", []byte("1"), time.Minute)
+				c.Set("b", []byte("2"), -time.Second)
+			},
+			want:    map[string]string{"a": "1"},
+			missing: []string{"b"},
+		},
+		{
+			name: "evicts the least recently used entry",
+			run: func(c *memoryCache) {
+				c.Set("a", []byte("1"), time.Minute)
+				c.Set("b", []byte("2"), time.Minute)
+				c.Get("a")
+				c.Set("c", []byte("3"), time.Minute)
+			},
+			want:    map[string]string{"a": "1", "c": "3"},
+			missing: []string{"b"},
+		},
+		{
+			name: "deletes entries",
+			run: func(c *memoryCache) {
+				c.Set("a", []byte("1"), time.Minute)
+				c.Delete("a")
+			},
+			want:    map[string]string{},
+			missing: []string{"a"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestMemoryCache(2)
+			tc.run(c)
+
+			for key, want := range tc.want {
+				value, err := c.Get(key)
+				if err != nil || string(value) != want {
+					t.Errorf("Get(%q) = %q, %v, want %q", key, value, err, want)
+				}
+			}
+			for _, key := range tc.missing {
+				if _, err := c.Get(key); !errors.Is(err, ErrCacheMiss) {
+					t.Errorf("Get(%q) error = %v, want ErrCacheMiss", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryCacheTagVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		invalidated []string
+		tags        []string
+		want        []int64
+	}{
+		{
+			name: "starts every tag at 0",
+			tags: []string{"all", "GetYouTubeVideoQuery"},
+			want: []int64{0, 0},
+		},
+		{
+			name:        "bumps only the invalidated tag",
+			invalidated: []string{"GetYouTubeVideoQuery"},
+			tags:        []string{"all", "GetYouTubeVideoQuery"},
+			want:        []int64{0, 1},
+		},
+		{
+			name:        "counts every invalidation",
+			invalidated: []string{"all", "all", "GetYouTubeVideoQuery"},
+			tags:        []string{"all", "GetYouTubeVideoQuery", "GetChannelQuery"},
+			want:        []int64{2, 1, 0},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestMemoryCache(2)
+			for _, tag := range tc.invalidated {
+				if _, err := c.InvalidateTag(tag); err != nil {
+					t.Fatalf("InvalidateTag(%q) error = %v", tag, err)
+				}
+			}
+
+			versions, err := c.TagVersions(tc.tags...)
+			if err != nil {
+				t.Fatalf("TagVersions() error = %v", err)
+			}
+			if !slices.Equal(versions, tc.want) {
+				t.Errorf("TagVersions() = %v, want %v", versions, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheUsage(t *testing.T) {
+	c := newTestMemoryCache(10)
+	c.Set("videos:GetYouTubeVideoQuery:0.0:a", []byte("12"), time.Minute)
+	c.Set("videos:GetYouTubeVideoQuery:0.0:b", []byte("345"), time.Minute)
+	c.Set("videos:GetYouTubeVideoQuery:0.1:a", []byte("6"), time.Minute)
+	c.Set("videos:GetChannelQuery:0.0:a", []byte("78"), time.Minute)
+
+	usage, err := c.Usage("videos:GetYouTubeVideoQuery:0.0:")
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if want := (CacheUsage{Keys: 2, Bytes: 5}); usage != want {
+		t.Errorf("Usage() = %+v, want %+v", usage, want)
+	}
+}
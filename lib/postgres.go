@@ -1,20 +1,28 @@
 package lib
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
-	"fampay-assignment/connections"
+	"fampay-assignment/config"
 	"fampay-assignment/logger"
 	"fampay-assignment/models"
+	"fampay-assignment/tracing"
 	"fampay-assignment/utils"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-
 func executePostgresQuery(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	queryName string,
 	query string,
@@ -23,57 +31,305 @@ func executePostgresQuery(
 	pgx.Rows,
 	error,
 ) {
-	
-	rows, err := db.Query(connections.GetContext(), query, queryArgs...)
+	ctx, span := startPostgresSpan(ctx, queryName)
+	ctx, cancel := context.WithTimeout(ctx, config.QUERY_TIMEOUT)
+	rows, err := db.Query(ctx, query, queryArgs...)
 	if err != nil {
+		cancel()
+		tracing.End(span, err)
 		logger.Log.WithFields(
 			logger.Fields{
 				"query": query,
 				"args":  queryArgs,
 			},
 		).Errorf("Error executing query %s: %v", queryName, err)
+		return rows, err
+	}
+	return &tracedRows{Rows: rows, span: span, cancel: cancel}, nil
+}
+
+// executePostgresQueryRow is executePostgresQuery for queries returning a
+// single row; the span ends once the row is scanned.
+func executePostgresQueryRow(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	queryName string,
+	query string,
+	queryArgs ...interface{},
+) pgx.Row {
+	ctx, span := startPostgresSpan(ctx, queryName)
+	ctx, cancel := context.WithTimeout(ctx, config.QUERY_TIMEOUT)
+	return &tracedRow{Row: db.QueryRow(ctx, query, queryArgs...), span: span, cancel: cancel}
+}
+
+func startPostgresSpan(ctx context.Context, queryName string) (context.Context, trace.Span) {
+	return tracing.StartChild(
+		ctx,
+		"postgres "+queryName,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", queryName),
+	)
+}
+
+// tracedRows ends the query's span and releases its timeout when the rows are
+// closed, so the span covers reading the rows and not just sending the query.
+type tracedRows struct {
+	pgx.Rows
+	span   trace.Span
+	cancel context.CancelFunc
+	closed bool
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	tracing.End(r.span, r.Rows.Err())
+	r.cancel()
+}
+
+type tracedRow struct {
+	pgx.Row
+	span   trace.Span
+	cancel context.CancelFunc
+}
+
+func (r *tracedRow) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	spanErr := err
+	if errors.Is(err, pgx.ErrNoRows) {
+		spanErr = nil
+	}
+	tracing.End(r.span, spanErr)
+	r.cancel()
+	return err
+}
+
+const videoColumns = `
+	videos.video_id, videos.title, videos.description, videos.published_at,
+	videos.thumbnail_url, videos.channel_title, videos.channel_id,
+	videos.view_count, videos.like_count, videos.comment_count,
+	videos.duration_seconds, videos.definition, videos.has_captions,
+	videos.stats_updated_at`
+
+// VideoSortColumns maps the sort_by values accepted by the API to columns.
+var VideoSortColumns = map[string]string{
+	"published_at":  "videos.published_at",
+	"view_count":    "videos.view_count",
+	"like_count":    "videos.like_count",
+	"comment_count": "videos.comment_count",
+}
+
+// videoFields lists scan targets in the order of videoColumns.
+func videoFields(video *models.Video) []any {
+	return []any{
+		&video.VideoID,
+		&video.Title,
+		&video.Description,
+		&video.PublishedAt,
+		&video.ThumbnailURL,
+		&video.ChannelTitle,
+		&video.ChannelID,
+		&video.ViewCount,
+		&video.LikeCount,
+		&video.CommentCount,
+		&video.DurationSeconds,
+		&video.Definition,
+		&video.HasCaptions,
+		&video.StatsUpdatedAt,
+	}
+}
+
+func scanVideo(row pgx.Row) (video models.Video, err error) {
+	err = row.Scan(videoFields(&video)...)
+	return video, err
+}
+
+// whereBuilder collects AND-ed conditions whose values are bound as
+// positional parameters rather than formatted into the SQL.
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// arg binds value and returns its placeholder.
+func (w *whereBuilder) arg(value any) string {
+	w.args = append(w.args, value)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// and adds a condition; every %s in it is replaced by the placeholder of the
+// matching value.
+func (w *whereBuilder) and(condition string, values ...any) {
+	placeholders := make([]any, len(values))
+	for i, value := range values {
+		placeholders[i] = w.arg(value)
+	}
+	w.conditions = append(w.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+func (w *whereBuilder) sql() string {
+	if len(w.conditions) == 0 {
+		return "TRUE"
 	}
-	return rows, err
+	return strings.Join(w.conditions, "\n\t\t\tAND ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is an ILIKE pattern matching text anywhere in a value.
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+func containsPatterns(texts []string) []string {
+	patterns := make([]string, len(texts))
+	for i, text := range texts {
+		patterns[i] = containsPattern(text)
+	}
+	return patterns
 }
 
 type GetLatestYouTubeVideoQueryParams struct {
 	PaginationPage int
 	PaginationSize int
-	PublishedAfter time.Time
-	SortOrder	   string
+	SortOrder      string
+	SortBy         string
+
+	// filters, zero values do not filter
+	PublishedAfter  time.Time
+	PublishedBefore time.Time
+	ChannelIDs      []string
+	ChannelTitle    string
+	IncludeKeywords []string
+	ExcludeKeywords []string
+	QueryID         int
+	TrackedQuery    string
+
+	// Keyset pages by (published_at, video_id) from Cursor instead of by
+	// page number; a nil Cursor starts at the beginning of the list
+	Keyset bool
+	Cursor *VideoCursor
 }
 
 type GetLatestYouTubeVideoQueryResult struct {
 	Videos []models.Video
-	Err    error
+	// more videos follow in the direction of travel
+	HasMore bool
+	Err     error
+}
+
+func reverseSortOrder(sortOrder string) string {
+	if sortOrder == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
+
+// videoFilters builds the WHERE clause shared by every listing of videos.
+func videoFilters(params *GetLatestYouTubeVideoQueryParams) *whereBuilder {
+	where := &whereBuilder{}
+
+	where.and("videos.published_at > %s", params.PublishedAfter)
+	if !params.PublishedBefore.IsZero() {
+		where.and("videos.published_at < %s", params.PublishedBefore)
+	}
+	if len(params.ChannelIDs) > 0 {
+		where.and("videos.channel_id = ANY(%s)", params.ChannelIDs)
+	}
+	if params.ChannelTitle != "" {
+		where.and("videos.channel_title ILIKE %s", containsPattern(params.ChannelTitle))
+	}
+	if len(params.IncludeKeywords) > 0 {
+		where.and("videos.title ILIKE ALL(%s)", containsPatterns(params.IncludeKeywords))
+	}
+	if len(params.ExcludeKeywords) > 0 {
+		where.and("NOT (videos.title ILIKE ANY(%s))", containsPatterns(params.ExcludeKeywords))
+	}
+	if params.QueryID != 0 {
+		where.and(
+			`EXISTS (
+				SELECT 1 FROM video_queries
+				WHERE video_queries.video_id = videos.video_id AND video_queries.query_id = %s
+			)`,
+			params.QueryID,
+		)
+	}
+	if params.TrackedQuery != "" {
+		where.and(
+			`EXISTS (
+				SELECT 1 FROM video_queries
+				JOIN tracked_queries ON tracked_queries.id = video_queries.query_id
+				WHERE video_queries.video_id = videos.video_id AND tracked_queries.query = %s
+			)`,
+			params.TrackedQuery,
+		)
+	}
+	return where
 }
 
 func getLatestYouTubeVideoQuery(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	params *GetLatestYouTubeVideoQueryParams,
 ) (response GetLatestYouTubeVideoQueryResult) {
 	response.Videos = []models.Video{}
 
+	where := videoFilters(params)
+	offset := utils.GetPaginationOffset(params.PaginationPage, params.PaginationSize)
+	order := fmt.Sprintf(
+		"%s %s NULLS LAST, videos.video_id %s",
+		VideoSortColumns[params.SortBy],
+		params.SortOrder,
+		params.SortOrder,
+	)
+	backward := false
+	if params.Keyset {
+		direction := params.SortOrder
+		if params.Cursor != nil && params.Cursor.Backward {
+			backward = true
+			direction = reverseSortOrder(direction)
+		}
+		order = fmt.Sprintf("videos.published_at %s, videos.video_id %s", direction, direction)
+		offset = 0
+
+		if params.Cursor != nil {
+			comparison := "<"
+			if direction == "asc" {
+				comparison = ">"
+			}
+			where.and(
+				"(videos.published_at, videos.video_id) "+comparison+" (%s, %s)",
+				params.Cursor.PublishedAt,
+				params.Cursor.VideoID,
+			)
+		}
+	}
+
 	query := fmt.Sprintf(
 		`SELECT
-			video_id, title, description, published_at, thumbnail_url, channel_title, channel_id
+			`+videoColumns+`
 		FROM
 			videos
 		WHERE
-			published_at > $1
+			%s
 		ORDER BY
-			published_at %s
-		LIMIT $2 OFFSET $3`,
-		params.SortOrder,
+			%s
+		LIMIT %s OFFSET %s`,
+		where.sql(),
+		order,
+		where.arg(params.PaginationSize+1),
+		where.arg(offset),
 	)
 
 	rows, err := executePostgresQuery(
+		ctx,
 		db,
 		"GetLatestYouTubeVideoQuery",
 		query,
-		params.PublishedAfter,
-		params.PaginationSize,
-		utils.GetPaginationOffset(params.PaginationPage, params.PaginationSize),
+		where.args...,
 	)
 	if err != nil {
 		response.Err = err
@@ -82,16 +338,7 @@ func getLatestYouTubeVideoQuery(
 	defer rows.Close()
 
 	for rows.Next() {
-		var video models.Video
-		err := rows.Scan(
-			&video.VideoID,
-			&video.Title,
-			&video.Description,
-			&video.PublishedAt,
-			&video.ThumbnailURL,
-			&video.ChannelTitle,
-			&video.ChannelID,
-		)
+		video, err := scanVideo(rows)
 		if err != nil {
 			response.Err = err
 			return response
@@ -99,14 +346,23 @@ func getLatestYouTubeVideoQuery(
 		response.Videos = append(response.Videos, video)
 	}
 
+	if len(response.Videos) > params.PaginationSize {
+		response.HasMore = true
+		response.Videos = response.Videos[:params.PaginationSize]
+	}
+	if backward {
+		slices.Reverse(response.Videos)
+	}
 	return response
 }
 
 func getLatestYouTubeVideoQueryCached(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	params *GetLatestYouTubeVideoQueryParams,
 ) (response GetLatestYouTubeVideoQueryResult) {
 	return cacheQuery(
+		ctx,
 		"GetLatestYouTubeVideoQuery",
 		getLatestYouTubeVideoQuery,
 		db,
@@ -115,23 +371,21 @@ func getLatestYouTubeVideoQueryCached(
 }
 
 func getLatestYouTubeVideoQueryAsync(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	params *GetLatestYouTubeVideoQueryParams,
 	ch chan<- GetLatestYouTubeVideoQueryResult,
 ) {
-	ch <- getLatestYouTubeVideoQueryCached(db, params)
+	ch <- getLatestYouTubeVideoQueryCached(ctx, db, params)
 }
 
 func GetLatestYouTubeVideos(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	params *GetLatestYouTubeVideoQueryParams,
 ) (response GetLatestYouTubeVideoQueryResult) {
-	if connections.RedisClient == nil {
-		return getLatestYouTubeVideoQueryCached(db, params)
-	}
-
-	videosChan := make(chan GetLatestYouTubeVideoQueryResult)
-	go getLatestYouTubeVideoQueryAsync(db, params, videosChan)
+	videosChan := make(chan GetLatestYouTubeVideoQueryResult, 1)
+	go getLatestYouTubeVideoQueryAsync(ctx, db, params, videosChan)
 
 	select {
 	case response = <-videosChan:
@@ -140,4 +394,182 @@ func GetLatestYouTubeVideos(
 	}
 
 	return response
-}
\ No newline at end of file
+}
+
+type GetYouTubeVideoQueryParams struct {
+	VideoID string
+}
+
+type GetYouTubeVideoQueryResult struct {
+	Video models.Video
+	Found bool
+	Err   error
+}
+
+func getYouTubeVideoQuery(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetYouTubeVideoQueryParams,
+) (response GetYouTubeVideoQueryResult) {
+	video, err := scanVideo(executePostgresQueryRow(
+		ctx,
+		db,
+		"GetYouTubeVideo",
+		`SELECT `+videoColumns+` FROM videos WHERE videos.video_id = $1`,
+		params.VideoID,
+	))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		response.Err = err
+	default:
+		response.Video = video
+		response.Found = true
+	}
+	return response
+}
+
+func getYouTubeVideoQueryCached(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetYouTubeVideoQueryParams,
+) (response GetYouTubeVideoQueryResult) {
+	return cacheQuery(
+		ctx,
+		"GetYouTubeVideoQuery",
+		getYouTubeVideoQuery,
+		db,
+		params,
+	)
+}
+
+func getYouTubeVideoQueryAsync(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetYouTubeVideoQueryParams,
+	ch chan<- GetYouTubeVideoQueryResult,
+) {
+	ch <- getYouTubeVideoQueryCached(ctx, db, params)
+}
+
+// GetYouTubeVideo looks up one stored video, cached per video id.
+func GetYouTubeVideo(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetYouTubeVideoQueryParams,
+) (response GetYouTubeVideoQueryResult) {
+	videoChan := make(chan GetYouTubeVideoQueryResult, 1)
+	go getYouTubeVideoQueryAsync(ctx, db, params, videoChan)
+
+	select {
+	case response = <-videoChan:
+	case <-time.After(2 * time.Second):
+		response.Err = fmt.Errorf("GetYouTubeVideo timed out")
+	}
+
+	return response
+}
+
+type CountYouTubeVideoQueryResult struct {
+	Total int64
+	// Total is the planner's estimate rather than an exact count
+	Estimated bool
+	Err       error
+}
+
+// countYouTubeVideoQuery counts matching videos exactly up to
+// COUNT_EXACT_LIMIT and falls back to the planner's row estimate beyond that,
+// so counting a large table never scans all of it.
+func countYouTubeVideoQuery(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetLatestYouTubeVideoQueryParams,
+) (response CountYouTubeVideoQueryResult) {
+	where := videoFilters(params)
+	query := fmt.Sprintf(
+		`SELECT count(*) FROM (SELECT 1 FROM videos WHERE %s LIMIT %s) matching`,
+		where.sql(),
+		where.arg(config.COUNT_EXACT_LIMIT+1),
+	)
+	err := executePostgresQueryRow(ctx, db, "CountYouTubeVideos", query, where.args...).Scan(&response.Total)
+	if err != nil || response.Total <= int64(config.COUNT_EXACT_LIMIT) {
+		response.Err = err
+		return response
+	}
+
+	where = videoFilters(params)
+	var plan []byte
+	err = executePostgresQueryRow(
+		ctx,
+		db,
+		"EstimateYouTubeVideos",
+		`EXPLAIN (FORMAT JSON) SELECT 1 FROM videos WHERE `+where.sql(),
+		where.args...,
+	).Scan(&plan)
+	if err != nil {
+		response.Err = err
+		return response
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &plans); err != nil || len(plans) == 0 {
+		response.Err = fmt.Errorf("unexpected query plan: %s", plan)
+		return response
+	}
+	response.Total = max(int64(plans[0].Plan.Rows), response.Total)
+	response.Estimated = true
+	return response
+}
+
+// countYouTubeVideoQueryCached caches counts per filter set, independent of
+// the page, sort and cursor of the request.
+func countYouTubeVideoQueryCached(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetLatestYouTubeVideoQueryParams,
+) (response CountYouTubeVideoQueryResult) {
+	filters := *params
+	filters.PaginationPage = 0
+	filters.PaginationSize = 0
+	filters.SortOrder = ""
+	filters.SortBy = ""
+	filters.Keyset = false
+	filters.Cursor = nil
+
+	return cacheQuery(
+		ctx,
+		"CountYouTubeVideoQuery",
+		countYouTubeVideoQuery,
+		db,
+		&filters,
+	)
+}
+
+func countYouTubeVideoQueryAsync(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetLatestYouTubeVideoQueryParams,
+	ch chan<- CountYouTubeVideoQueryResult,
+) {
+	ch <- countYouTubeVideoQueryCached(ctx, db, params)
+}
+
+func CountYouTubeVideos(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *GetLatestYouTubeVideoQueryParams,
+) (response CountYouTubeVideoQueryResult) {
+	countChan := make(chan CountYouTubeVideoQueryResult, 1)
+	go countYouTubeVideoQueryAsync(ctx, db, params, countChan)
+
+	select {
+	case response = <-countChan:
+	case <-time.After(2 * time.Second):
+		response.Err = fmt.Errorf("CountYouTubeVideos timed out")
+	}
+
+	return response
+}
@@ -0,0 +1,180 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fampay-assignment/connections"
+	"fampay-assignment/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	trackedQueryColumns = `id, query, active, watermark, created_at, updated_at`
+	uniqueViolationCode = "23505"
+)
+
+var (
+	ErrTrackedQueryNotFound = errors.New("tracked query not found")
+	ErrTrackedQueryExists   = errors.New("query is already tracked")
+)
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
+
+func scanTrackedQuery(row pgx.Row) (query models.TrackedQuery, err error) {
+	err = row.Scan(
+		&query.ID,
+		&query.Query,
+		&query.Active,
+		&query.Watermark,
+		&query.CreatedAt,
+		&query.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = ErrTrackedQueryNotFound
+	}
+	return query, err
+}
+
+func listTrackedQueries(ctx context.Context, db *pgxpool.Pool, onlyActive bool) ([]models.TrackedQuery, error) {
+	queries := []models.TrackedQuery{}
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"ListTrackedQueries",
+		`SELECT `+trackedQueryColumns+`
+		FROM tracked_queries
+		WHERE active OR NOT $1
+		ORDER BY id`,
+		onlyActive,
+	)
+	if err != nil {
+		return queries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		query, err := scanTrackedQuery(rows)
+		if err != nil {
+			return queries, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+func ListTrackedQueries(ctx context.Context, db *pgxpool.Pool) ([]models.TrackedQuery, error) {
+	return listTrackedQueries(ctx, db, false)
+}
+
+func ListActiveTrackedQueries(ctx context.Context, db *pgxpool.Pool) ([]models.TrackedQuery, error) {
+	return listTrackedQueries(ctx, db, true)
+}
+
+func CreateTrackedQuery(db *pgxpool.Pool, query string, active bool) (models.TrackedQuery, error) {
+	trackedQuery, err := scanTrackedQuery(db.QueryRow(
+		connections.GetContext(),
+		`INSERT INTO tracked_queries (query, active)
+		VALUES ($1, $2)
+		RETURNING `+trackedQueryColumns,
+		query,
+		active,
+	))
+	if isUniqueViolation(err) {
+		err = ErrTrackedQueryExists
+	}
+	return trackedQuery, err
+}
+
+// getOrCreateTrackedQuery returns the tracked query for the text, creating it
+// inactive when missing so a backfill does not also enlist it for live polling.
+func getOrCreateTrackedQuery(db *pgxpool.Pool, query string) (models.TrackedQuery, error) {
+	return scanTrackedQuery(db.QueryRow(
+		connections.GetContext(),
+		`INSERT INTO tracked_queries (query, active)
+		VALUES ($1, FALSE)
+		ON CONFLICT (query) DO UPDATE SET query = EXCLUDED.query
+		RETURNING `+trackedQueryColumns,
+		query,
+	))
+}
+
+// UpdateTrackedQuery changes only the fields that are not nil. Changing the
+// query text makes it a new search: its watermark is reset and the videos
+// surfaced by the old text are no longer attributed to it.
+func UpdateTrackedQuery(db *pgxpool.Pool, id int, query *string, active *bool) (models.TrackedQuery, error) {
+	trackedQuery, err := scanTrackedQuery(db.QueryRow(
+		connections.GetContext(),
+		`WITH unlinked AS (
+			DELETE FROM video_queries
+			WHERE query_id = $1 AND EXISTS (
+				SELECT 1 FROM tracked_queries WHERE id = $1 AND query <> $2
+			)
+		)
+		UPDATE tracked_queries
+		SET
+			query = COALESCE($2, query),
+			active = COALESCE($3, active),
+			watermark = CASE WHEN query <> $2 THEN NULL ELSE watermark END,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+trackedQueryColumns,
+		id,
+		query,
+		active,
+	))
+	if isUniqueViolation(err) {
+		err = ErrTrackedQueryExists
+	}
+	return trackedQuery, err
+}
+
+func DeleteTrackedQuery(db *pgxpool.Pool, id int) error {
+	tag, err := db.Exec(
+		connections.GetContext(),
+		`DELETE FROM tracked_queries WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTrackedQueryNotFound
+	}
+	return nil
+}
+
+// SetTrackedQueryWatermark records the publishedAfter cursor the next fetch
+// cycle of the query resumes from. It never moves the watermark backwards.
+func SetTrackedQueryWatermark(db *pgxpool.Pool, id int, watermark time.Time) error {
+	_, err := executeQuery(
+		db,
+		`UPDATE tracked_queries
+		SET watermark = GREATEST(watermark, $2)
+		WHERE id = $1`,
+		id,
+		watermark,
+	)
+	return err
+}
+
+// seedTrackedQueries makes sure a fresh database tracks the default search
+// query, without resurrecting it once an operator has removed it.
+func seedTrackedQueries(db *pgxpool.Pool, defaultQuery string) error {
+	_, err := executeQuery(
+		db,
+		`INSERT INTO tracked_queries (query)
+		SELECT $1
+		WHERE NOT EXISTS (SELECT 1 FROM tracked_queries)`,
+		defaultQuery,
+	)
+	return err
+}
@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/logger"
+	"fampay-assignment/models"
+)
+
+var (
+	// interval the fetcher currently waits between cycles
+	currentFetchInterval atomic.Int64
+)
+
+type KeyQuotaUsage struct {
+	Key            models.YouTubeAPIKey
+	UnitsUsed      int
+	UnitsRemaining int
+	Calls          int
+}
+
+type QuotaUsage struct {
+	Day              string
+	ResetsAt         time.Time
+	DailyQuotaPerKey int
+	UnitsUsed        int
+	UnitsRemaining   int
+	FetchInterval    time.Duration
+	Keys             []KeyQuotaUsage
+}
+
+// quotaDay is the Pacific calendar day quota usage is accounted against.
+func quotaDay(now time.Time) string {
+	return now.In(quotaResetLocation).Format("2006-01-02")
+}
+
+// rollUsageDayLocked starts a fresh day of accounting once the quota has reset.
+func (k *APIKeys) rollUsageDayLocked(now time.Time) {
+	if day := quotaDay(now); day != k.usageDay {
+		k.usageDay = day
+		k.unitsToday = map[string]int{}
+		k.callsToday = map[string]int{}
+	}
+}
+
+// loadUsageLocked restores today's accounting after a restart.
+func (k *APIKeys) loadUsageLocked(now time.Time) error {
+	k.rollUsageDayLocked(now)
+
+	rows, err := executePostgresQuery(
+		context.Background(),
+		k.db,
+		"LoadYouTubeAPIKeyUsage",
+		`SELECT youtube_api_keys.key, youtube_api_key_usage.units, youtube_api_key_usage.calls
+		FROM youtube_api_key_usage
+		JOIN youtube_api_keys ON youtube_api_keys.id = youtube_api_key_usage.key_id
+		WHERE youtube_api_key_usage.day = $1`,
+		k.usageDay,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			value string
+			units int
+			calls int
+		)
+		if err := rows.Scan(&value, &units, &calls); err != nil {
+			return err
+		}
+		k.unitsToday[value] = units
+		k.callsToday[value] = calls
+	}
+	return rows.Err()
+}
+
+// Charge records the estimated quota cost of one call made with the key.
+func (k *APIKeys) Charge(value string, units int) {
+	now := time.Now()
+
+	k.mu.Lock()
+	db := k.db
+	k.rollUsageDayLocked(now)
+	k.unitsToday[value] += units
+	k.callsToday[value]++
+	day := k.usageDay
+	keyID := 0
+	if key := k.findLocked(value); key != nil {
+		keyID = key.ID
+	}
+	k.mu.Unlock()
+
+	if db == nil || keyID == 0 {
+		return
+	}
+	_, err := executeQuery(
+		db,
+		`INSERT INTO youtube_api_key_usage (key_id, day, units, calls)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (key_id, day) DO UPDATE SET
+			units = youtube_api_key_usage.units + EXCLUDED.units,
+			calls = youtube_api_key_usage.calls + 1`,
+		keyID,
+		day,
+		units,
+	)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to record api key quota usage")
+	}
+}
+
+// remainingLocked estimates the units the key can still spend today.
+func (k *APIKeys) remainingLocked(key *models.YouTubeAPIKey) int {
+	if key.State != models.KeyActive {
+		return 0
+	}
+	return max(0, config.YOUTUBE_DAILY_QUOTA-k.unitsToday[key.Key])
+}
+
+func (k *APIKeys) keyUsageLocked(key *models.YouTubeAPIKey) KeyQuotaUsage {
+	return KeyQuotaUsage{
+		Key:            *key,
+		UnitsUsed:      k.unitsToday[key.Key],
+		UnitsRemaining: k.remainingLocked(key),
+		Calls:          k.callsToday[key.Key],
+	}
+}
+
+// Usage reports today's estimated quota consumption of every key.
+func (k *APIKeys) Usage() QuotaUsage {
+	now := time.Now()
+
+	k.mu.Lock()
+	db := k.db
+	changed := k.refreshLocked(now)
+	k.rollUsageDayLocked(now)
+
+	usage := QuotaUsage{
+		Day:              k.usageDay,
+		ResetsAt:         nextQuotaReset(now),
+		DailyQuotaPerKey: config.YOUTUBE_DAILY_QUOTA,
+		FetchInterval:    time.Duration(currentFetchInterval.Load()),
+		Keys:             []KeyQuotaUsage{},
+	}
+	for _, key := range k.keys {
+		keyUsage := k.keyUsageLocked(key)
+		usage.UnitsUsed += keyUsage.UnitsUsed
+		usage.UnitsRemaining += keyUsage.UnitsRemaining
+		usage.Keys = append(usage.Keys, keyUsage)
+	}
+	k.mu.Unlock()
+
+	persistAPIKeys(db, changed...)
+	return usage
+}
+
+// RemainingUnits estimates the units all active keys can still spend today.
+func (k *APIKeys) RemainingUnits() int {
+	return k.Usage().UnitsRemaining
+}
+
+// nextFetchInterval spreads the remaining daily budget of all keys over the
+// time left until the quota resets, assuming each cycle keeps costing about
+// cycleUnits, and clamps the result to the configured bounds.
+func nextFetchInterval(now time.Time, cycleUnits float64, remainingUnits int) time.Duration {
+	interval := config.MAX_FETCH_INTERVAL
+	if cycles := float64(remainingUnits) / max(cycleUnits, 1); cycles >= 1 {
+		interval = time.Duration(float64(nextQuotaReset(now).Sub(now)) / cycles)
+	}
+	return min(max(interval, config.MIN_FETCH_INTERVAL), config.MAX_FETCH_INTERVAL)
+}
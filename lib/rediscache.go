@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"strconv"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/connections"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCache shares entries between every instance through Redis. Tag
+// versions are plain counters under videos:tag:<tag>.
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(client *redis.Client) *redisCache {
+	return &redisCache{client: client}
+}
+
+func redisTagKey(tag string) string {
+	return "videos:tag:" + tag
+}
+
+func (c *redisCache) Name() string {
+	return config.CACHE_BACKEND_REDIS
+}
+
+func (c *redisCache) Get(key string) ([]byte, error) {
+	value, err := c.client.Get(connections.GetRedisContext(), key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	return value, err
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(connections.GetRedisContext(), key, value, ttl).Err()
+}
+
+func (c *redisCache) Delete(key string) error {
+	return c.client.Del(connections.GetRedisContext(), key).Err()
+}
+
+func (c *redisCache) TagVersions(tags ...string) ([]int64, error) {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = redisTagKey(tag)
+	}
+	values, err := c.client.MGet(connections.GetRedisContext(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(tags))
+	for i, value := range values {
+		// tags that were never invalidated have no counter yet
+		if value == nil {
+			continue
+		}
+		versions[i], err = strconv.ParseInt(value.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+func (c *redisCache) InvalidateTag(tag string) (int64, error) {
+	return c.client.Incr(connections.GetRedisContext(), redisTagKey(tag)).Result()
+}
+
+// Usage walks the keyspace with SCAN and sums STRLEN of the matches, so it
+// is meant for admin use only.
+func (c *redisCache) Usage(prefix string) (usage CacheUsage, err error) {
+	ctx := connections.GetRedisContext()
+
+	var cursor uint64
+	for {
+		var keys []string
+		keys, cursor, err = c.client.Scan(ctx, cursor, prefix+"*", 1000).Result()
+		if err != nil {
+			return usage, err
+		}
+
+		if len(keys) > 0 {
+			pipe := c.client.Pipeline()
+			lengths := make([]*redis.IntCmd, len(keys))
+			for i, key := range keys {
+				lengths[i] = pipe.StrLen(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return usage, err
+			}
+			for _, length := range lengths {
+				usage.Keys++
+				usage.Bytes += length.Val()
+			}
+		}
+
+		if cursor == 0 {
+			return usage, nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"fampay-assignment/models"
+	"fampay-assignment/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PrefixTSQuery turns free text into a to_tsquery expression that requires
+// every word as a prefix, so "tea how" matches "How to make tea?". Anything
+// but letters and digits separates words, which also keeps tsquery operators
+// out of the expression. It returns "" when the text has no words.
+func PrefixTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+const (
+	SearchModeFullText = "fulltext"
+	SearchModeFuzzy    = "fuzzy"
+)
+
+// searchModes holds the FROM list, match condition and score of each search
+// mode, all written against the search terms in $1.
+var searchModes = map[string]struct {
+	from  string
+	match string
+	score string
+}{
+	// prefix tsquery against the generated search_vector, ranked by ts_rank
+	SearchModeFullText: {
+		from:  `videos, to_tsquery('english', $1) AS search(query)`,
+		match: `videos.search_vector @@ search.query`,
+		score: `ts_rank(videos.search_vector, search.query)`,
+	},
+	// pg_trgm word similarity of the raw text against titles, channel titles
+	// and descriptions; <% matches above pg_trgm.word_similarity_threshold
+	SearchModeFuzzy: {
+		from: `videos`,
+		match: `($1 <% videos.title
+			OR $1 <% videos.channel_title
+			OR $1 <% videos.description)`,
+		score: `GREATEST(
+			word_similarity($1, videos.title),
+			word_similarity($1, coalesce(videos.channel_title, '')),
+			word_similarity($1, coalesce(videos.description, ''))
+		)`,
+	},
+}
+
+// SearchSortColumns maps the sort_by values accepted by search to columns;
+// relevance sorts by score.
+var SearchSortColumns = map[string]string{
+	"relevance":     "score",
+	"published_at":  "videos.published_at",
+	"view_count":    "videos.view_count",
+	"like_count":    "videos.like_count",
+	"comment_count": "videos.comment_count",
+}
+
+type SearchYouTubeVideoQueryParams struct {
+	Mode string
+	// prefix tsquery in fulltext mode, the raw text in fuzzy mode
+	Terms          string
+	PaginationPage int
+	PaginationSize int
+	PublishedAfter time.Time
+	RecencyWeight  float64
+	SortBy         string
+	SortOrder      string
+}
+
+type SearchYouTubeVideoQueryResult struct {
+	Videos  []models.ScoredVideo
+	HasMore bool
+	Err     error
+}
+
+// searchYouTubeVideoQuery scores matches by the mode's score divided by
+// 1 + recency_weight * age in days so a positive weight favours recent videos.
+func searchYouTubeVideoQuery(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *SearchYouTubeVideoQueryParams,
+) (response SearchYouTubeVideoQueryResult) {
+	response.Videos = []models.ScoredVideo{}
+
+	mode := searchModes[params.Mode]
+	query := fmt.Sprintf(
+		`SELECT
+			`+videoColumns+`,
+			%s / (1 + $5::float8 * EXTRACT(EPOCH FROM NOW() - videos.published_at)::float8 / 86400) AS score
+		FROM
+			%s
+		WHERE
+			%s
+			AND videos.published_at > $2
+		ORDER BY
+			%s %s NULLS LAST, score DESC, videos.published_at DESC
+		LIMIT $3 OFFSET $4`,
+		mode.score,
+		mode.from,
+		mode.match,
+		SearchSortColumns[params.SortBy],
+		params.SortOrder,
+	)
+
+	rows, err := executePostgresQuery(
+		ctx,
+		db,
+		"SearchYouTubeVideoQuery",
+		query,
+		params.Terms,
+		params.PublishedAfter,
+		params.PaginationSize+1,
+		utils.GetPaginationOffset(params.PaginationPage, params.PaginationSize),
+		params.RecencyWeight,
+	)
+	if err != nil {
+		response.Err = err
+		return response
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var video models.ScoredVideo
+		err := rows.Scan(append(videoFields(&video.Video), &video.Score)...)
+		if err != nil {
+			response.Err = err
+			return response
+		}
+		response.Videos = append(response.Videos, video)
+	}
+	response.Err = rows.Err()
+
+	if len(response.Videos) > params.PaginationSize {
+		response.HasMore = true
+		response.Videos = response.Videos[:params.PaginationSize]
+	}
+
+	return response
+}
+
+func searchYouTubeVideoQueryCached(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *SearchYouTubeVideoQueryParams,
+) (response SearchYouTubeVideoQueryResult) {
+	return cacheQuery(
+		ctx,
+		"SearchYouTubeVideoQuery",
+		searchYouTubeVideoQuery,
+		db,
+		params,
+	)
+}
+
+func searchYouTubeVideoQueryAsync(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *SearchYouTubeVideoQueryParams,
+	ch chan<- SearchYouTubeVideoQueryResult,
+) {
+	ch <- searchYouTubeVideoQueryCached(ctx, db, params)
+}
+
+func SearchYouTubeVideos(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *SearchYouTubeVideoQueryParams,
+) (response SearchYouTubeVideoQueryResult) {
+	videosChan := make(chan SearchYouTubeVideoQueryResult, 1)
+	go searchYouTubeVideoQueryAsync(ctx, db, params, videosChan)
+
+	select {
+	case response = <-videosChan:
+	case <-time.After(2 * time.Second):
+		response.Err = fmt.Errorf("SearchYouTubeVideos timed out")
+	}
+
+	return response
+}
@@ -0,0 +1,306 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/metrics"
+	"fampay-assignment/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const httpTimeout = 30 * time.Second
+
+// youTubeApiBaseUrl is a variable so tests can point calls elsewhere.
+var youTubeApiBaseUrl = "https://www.googleapis.com/youtube/v3/"
+
+type YouTubeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Errors  []struct {
+		Message string `json:"message"`
+		Domain  string `json:"domain"`
+		Reason  string `json:"reason"`
+	} `json:"errors"`
+}
+
+func (e *YouTubeError) reason() string {
+	if len(e.Errors) == 0 {
+		return ""
+	}
+	return e.Errors[0].Reason
+}
+
+func (e *YouTubeError) isQuotaExceeded() bool {
+	switch e.reason() {
+	case "quotaExceeded", "dailyLimitExceeded":
+		return true
+	}
+	return false
+}
+
+// isKeyInvalid reports errors caused by the key itself rather than the
+// request: revoked, expired, restricted or without the API enabled.
+func (e *YouTubeError) isKeyInvalid() bool {
+	switch e.reason() {
+	case "keyInvalid", "keyExpired", "accessNotConfigured", "ipRefererBlocked":
+		return true
+	}
+	return e.Code == 400 && strings.Contains(e.Message, "API key not valid")
+}
+
+type YouTubeResponse struct {
+	NextPageToken string `json:"nextPageToken"`
+	Items         []struct {
+		ID struct {
+			VideoID string `json:"videoId"`
+		} `json:"id"`
+		Snippet struct {
+			PublishedAt time.Time `json:"publishedAt"`
+			ChannelID   string    `json:"channelId"`
+			Title       string    `json:"title"`
+			Description string    `json:"description"`
+			Thumbnails  struct {
+				High struct {
+					URL string `json:"url"`
+				} `json:"default"`
+			} `json:"thumbnails"`
+			ChannelTitle string `json:"channelTitle"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
+
+// YouTubeVideo is one videos.list item; only the requested parts are set.
+type YouTubeVideo struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		PublishedAt time.Time `json:"publishedAt"`
+		ChannelID   string    `json:"channelId"`
+		Title       string    `json:"title"`
+		Description string    `json:"description"`
+		Thumbnails  struct {
+			High struct {
+				URL string `json:"url"`
+			} `json:"default"`
+		} `json:"thumbnails"`
+		ChannelTitle string `json:"channelTitle"`
+	} `json:"snippet"`
+	Statistics struct {
+		ViewCount    string `json:"viewCount"`
+		LikeCount    string `json:"likeCount"`
+		CommentCount string `json:"commentCount"`
+	} `json:"statistics"`
+	ContentDetails struct {
+		Duration   string `json:"duration"`
+		Definition string `json:"definition"`
+		Caption    string `json:"caption"`
+	} `json:"contentDetails"`
+}
+
+type YouTubeVideosResponse struct {
+	Items []YouTubeVideo `json:"items"`
+}
+
+type YouTubeChannelsResponse struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title       string    `json:"title"`
+			Description string    `json:"description"`
+			CustomURL   string    `json:"customUrl"`
+			PublishedAt time.Time `json:"publishedAt"`
+			Country     string    `json:"country"`
+			Thumbnails  struct {
+				High struct {
+					URL string `json:"url"`
+				} `json:"default"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+		Statistics struct {
+			ViewCount             string `json:"viewCount"`
+			SubscriberCount       string `json:"subscriberCount"`
+			HiddenSubscriberCount bool   `json:"hiddenSubscriberCount"`
+			VideoCount            string `json:"videoCount"`
+		} `json:"statistics"`
+	} `json:"items"`
+}
+
+// callYouTubeAPI performs a GET against a YouTube Data API resource with the
+// next active API key and decodes the body into response.
+func callYouTubeAPI(ctx context.Context, resource string, cost int, query url.Values, response any) error {
+	apiKey, err := ApiKeys.Acquire()
+	if err != nil {
+		return err
+	}
+	return callYouTubeAPIWithKey(ctx, apiKey, resource, cost, query, response)
+}
+
+// reportTransportError counts a failed call against the key unless it only
+// ran out of time, which says nothing about the key.
+func reportTransportError(apiKey string, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	ApiKeys.ReportError(apiKey, err.Error())
+}
+
+// redactURLError replaces the request URL in errors of the HTTP client, which
+// carries the API key in its query string, with the bare resource URL.
+func redactURLError(err error, resource string) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return fmt.Errorf("%s %s: %w", urlErr.Op, youTubeApiBaseUrl+resource, urlErr.Err)
+}
+
+// callYouTubeAPIWithKey performs the call with the given key, charging the
+// call's quota cost to the key and reporting quota, key and transient errors
+// back to the key pool.
+func callYouTubeAPIWithKey(ctx context.Context, apiKey string, resource string, cost int, query url.Values, response any) (err error) {
+	ctx, span := tracing.StartChild(
+		ctx,
+		"youtube "+resource,
+		attribute.String("youtube.resource", resource),
+		attribute.Int("youtube.quota_cost", cost),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// a call that reached YouTube is charged whether or not the caller still
+	// waits for it, so it runs to completion under its own timeout instead of
+	// the deadline of the request that started it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), httpTimeout)
+	defer cancel()
+
+	query.Set("key", apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youTubeApiBaseUrl+resource+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", redactURLError(err, resource))
+	}
+
+	// every error below is reported, logged and traced, so none may carry the
+	// key from the request URL
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		err = redactURLError(err, resource)
+		metrics.YouTubeAPIErrors.WithLabelValues(resource, "transport").Inc()
+		reportTransportError(apiKey, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = redactURLError(err, resource)
+		metrics.YouTubeAPIErrors.WithLabelValues(resource, "transport").Inc()
+		reportTransportError(apiKey, err)
+		return fmt.Errorf("error reading YouTube API response: %w", err)
+	}
+
+	var errorResponse struct {
+		Error YouTubeError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResponse); err != nil {
+		metrics.YouTubeAPIErrors.WithLabelValues(resource, "decode").Inc()
+		return fmt.Errorf("error decoding YouTube API response: %v", err)
+	}
+
+	ytError := errorResponse.Error
+	if ytError.Code != 0 {
+		reason := ytError.reason()
+		if reason == "" {
+			reason = "http_" + strconv.Itoa(ytError.Code)
+		}
+		metrics.YouTubeAPIErrors.WithLabelValues(resource, reason).Inc()
+	}
+	if !ytError.isQuotaExceeded() {
+		ApiKeys.Charge(apiKey, cost)
+	}
+	switch {
+	case ytError.Code == 0:
+		ApiKeys.ReportSuccess(apiKey)
+	case ytError.isQuotaExceeded():
+		ApiKeys.ReportQuotaExceeded(apiKey, ytError.Message)
+		return fmt.Errorf("quota exceeded: %s", ytError.Message)
+	case ytError.isKeyInvalid():
+		ApiKeys.ReportInvalid(apiKey, ytError.Message)
+		return fmt.Errorf("invalid API key: %s", ytError.Message)
+	case ytError.Code >= 500:
+		ApiKeys.ReportError(apiKey, ytError.Message)
+		return fmt.Errorf("API error: %s", ytError.Message)
+	default:
+		return fmt.Errorf("API error: %s", ytError.Message)
+	}
+
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("error decoding YouTube API response: %v", err)
+	}
+	return nil
+}
+
+func fetchSearchPage(ctx context.Context, searchQuery string, window fetchWindow, pageToken string) (*YouTubeResponse, error) {
+	query := url.Values{}
+	query.Set("part", "snippet")
+	query.Set("type", "video")
+	query.Set("order", "date")
+	query.Set("q", searchQuery)
+	query.Set("maxResults", strconv.Itoa(config.YOUTUBE_MAX_RESULTS))
+	query.Set("publishedAfter", window.PublishedAfter.UTC().Format(config.DATE_FORMAT))
+	if !window.PublishedBefore.IsZero() {
+		query.Set("publishedBefore", window.PublishedBefore.UTC().Format(config.DATE_FORMAT))
+	}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+
+	var ytResponse YouTubeResponse
+	if err := callYouTubeAPI(ctx, "search", config.YOUTUBE_SEARCH_COST, query, &ytResponse); err != nil {
+		return nil, err
+	}
+	return &ytResponse, nil
+}
+
+// fetchVideoDetails looks up statistics and content details for up to
+// YOUTUBE_MAX_RESULTS video ids in one videos.list call.
+func fetchVideoDetails(ctx context.Context, videoIDs []string) (*YouTubeVideosResponse, error) {
+	return fetchVideos(ctx, videoIDs, "statistics,contentDetails")
+}
+
+func fetchVideos(ctx context.Context, videoIDs []string, parts string) (*YouTubeVideosResponse, error) {
+	query := url.Values{}
+	query.Set("part", parts)
+	query.Set("id", strings.Join(videoIDs, ","))
+	query.Set("maxResults", strconv.Itoa(config.YOUTUBE_MAX_RESULTS))
+
+	var ytResponse YouTubeVideosResponse
+	if err := callYouTubeAPI(ctx, "videos", config.YOUTUBE_VIDEOS_LIST_COST, query, &ytResponse); err != nil {
+		return nil, err
+	}
+	return &ytResponse, nil
+}
+
+func fetchChannels(ctx context.Context, channelIDs []string) (*YouTubeChannelsResponse, error) {
+	query := url.Values{}
+	query.Set("part", "snippet,statistics")
+	query.Set("id", strings.Join(channelIDs, ","))
+	query.Set("maxResults", strconv.Itoa(config.YOUTUBE_MAX_RESULTS))
+
+	var ytResponse YouTubeChannelsResponse
+	if err := callYouTubeAPI(ctx, "channels", config.YOUTUBE_CHANNELS_LIST_COST, query, &ytResponse); err != nil {
+		return nil, err
+	}
+	return &ytResponse, nil
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"fampay-assignment/models"
+)
+
+// closedServerURL returns the URL of a port nothing listens on anymore.
+func closedServerURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return "http://" + address + "/"
+}
+
+// hangUpServerURL returns the URL of a server that drops every connection
+// without answering.
+func hangUpServerURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/"
+}
+
+// truncatedServerURL returns the URL of a server that promises a longer body
+// than it sends.
+func truncatedServerURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte(`{"items":`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/"
+}
+
+func TestTransportErrorsHideKey(t *testing.T) {
+	const apiKey = "AIzaSyTestKeyThatMustNotLeak"
+
+	tests := []struct {
+		name    string
+		baseURL func(t *testing.T) string
+	}{
+		{name: "connection refused", baseURL: closedServerURL},
+		{name: "connection dropped", baseURL: hangUpServerURL},
+		{name: "response cut short", baseURL: truncatedServerURL},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			baseURL, keys := youTubeApiBaseUrl, ApiKeys
+			t.Cleanup(func() {
+				youTubeApiBaseUrl, ApiKeys = baseURL, keys
+			})
+			youTubeApiBaseUrl = tc.baseURL(t)
+			ApiKeys = NewAPIKeys()
+			key := &models.YouTubeAPIKey{Key: apiKey, State: models.KeyActive}
+			ApiKeys.keys = []*models.YouTubeAPIKey{key}
+
+			var response YouTubeResponse
+			err := callYouTubeAPIWithKey(context.Background(), apiKey, "search", 100, url.Values{}, &response)
+			if err == nil {
+				t.Fatal("callYouTubeAPIWithKey() succeeded, want a transport error")
+			}
+			if strings.Contains(err.Error(), apiKey) {
+				t.Errorf("error %q contains the API key", err)
+			}
+			if key.LastError == nil {
+				t.Fatal("the transport error was not reported to the key pool")
+			}
+			if strings.Contains(*key.LastError, apiKey) {
+				t.Errorf("reported error %q contains the API key", *key.LastError)
+			}
+		})
+	}
+}
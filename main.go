@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 	"runtime"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -10,17 +11,23 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"fampay-assignment/config"
+	"fampay-assignment/connections"
+	"fampay-assignment/lib"
 	"fampay-assignment/logger"
 	"fampay-assignment/routes"
+	"fampay-assignment/tracing"
 )
 
 var router *gin.Engine
 var ginLambda *ginadapter.GinLambda
 
-func init() {
-	logger.Log.Info("starting the server")
-	router = routes.Router()
-	ginLambda = ginadapter.New(router)
+// setup loads the configuration and opens the connections and caches every
+// command of the binary relies on.
+func setup() {
+	config.Load()
+	connections.Connect()
+	lib.SetupQueryCache()
+	lib.LoadApiKeys()
 }
 
 func GinRequestHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -28,6 +35,31 @@ func GinRequestHandler(ctx context.Context, request events.APIGatewayProxyReques
 }
 
 func main() {
+	setup()
+
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to set up tracing, spans are dropped")
+	}
+	defer shutdownTracing(context.Background())
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			code := runBackfillCommand(os.Args[2:])
+			// os.Exit skips the deferred flush
+			shutdownTracing(context.Background())
+			os.Exit(code)
+		}
+	}
+
+	logger.Log.Info("starting the server")
+	router = routes.Router()
+	ginLambda = ginadapter.New(router)
+
+	go lib.StartFetchingVideos(context.Background())
+	go lib.StartEnrichingVideos(context.Background())
+
 	port := fmt.Sprintf(":%s", config.Port)
 	logger.Log.WithFields(logger.Fields{
 		"port":       port,
@@ -0,0 +1,79 @@
+// Package metrics holds the Prometheus collectors of the API, the fetcher,
+// the query cache and the database pool, all served on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	FetchCycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fetch_cycle_duration_seconds",
+		Help:    "Duration of fetch cycles over every active tracked query.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+
+	VideosInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fetch_videos_inserted_total",
+		Help: "Videos the fetcher stored, by tracked query id.",
+	}, []string{"query_id"})
+
+	// reason is filtered for the channel allow/block lists, watermark for
+	// videos at or before the publishedAfter bound, known for videos the query
+	// already surfaced, existing for videos another query already stored and
+	// failed for videos that could not be stored
+	VideosSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fetch_videos_skipped_total",
+		Help: "Search results the fetcher did not store, by tracked query id and reason.",
+	}, []string{"query_id", "reason"})
+
+	YouTubeAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "youtube_api_errors_total",
+		Help: "Failed YouTube Data API calls by resource and error reason.",
+	}, []string{"resource", "reason"})
+
+	// result is hit, stale (served while refreshing), miss or error
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cached query lookups by query and result.",
+	}, []string{"query", "result"})
+)
+
+// RegisterAPIKeyPool exports the number of API keys in each state, read from
+// countByState on every scrape.
+func RegisterAPIKeyPool(countByState func() map[string]int) {
+	prometheus.MustRegister(&keyPoolCollector{countByState: countByState})
+}
+
+var keyPoolDesc = prometheus.NewDesc(
+	"youtube_api_keys",
+	"YouTube API keys in the pool by state.",
+	[]string{"state"},
+	nil,
+)
+
+type keyPoolCollector struct {
+	countByState func() map[string]int
+}
+
+func (c *keyPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- keyPoolDesc
+}
+
+func (c *keyPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for state, count := range c.countByState() {
+		ch <- prometheus.MustNewConstMetric(keyPoolDesc, prometheus.GaugeValue, float64(count), state)
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterPostgresPool exports the statistics of the pool on every scrape.
+func RegisterPostgresPool(db *pgxpool.Pool) {
+	prometheus.MustRegister(&poolCollector{db: db})
+}
+
+func poolDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc("pgxpool_"+name, help, nil, nil)
+}
+
+var (
+	poolAcquiredConns     = poolDesc("acquired_conns", "Connections currently in use.")
+	poolIdleConns         = poolDesc("idle_conns", "Idle connections.")
+	poolTotalConns        = poolDesc("total_conns", "Open connections.")
+	poolMaxConns          = poolDesc("max_conns", "Maximum size of the pool.")
+	poolAcquires          = poolDesc("acquires_total", "Successful connection acquires.")
+	poolAcquireSeconds    = poolDesc("acquire_duration_seconds_total", "Time spent acquiring connections.")
+	poolEmptyAcquires     = poolDesc("empty_acquires_total", "Acquires that had to wait for a connection.")
+	poolCanceledAcquires  = poolDesc("canceled_acquires_total", "Acquires canceled by their context.")
+	poolNewConns          = poolDesc("new_conns_total", "Connections opened.")
+	poolMaxLifetimeCloses = poolDesc("max_lifetime_destroys_total", "Connections closed for exceeding their lifetime.")
+	poolMaxIdleCloses     = poolDesc("max_idle_destroys_total", "Connections closed for idling too long.")
+)
+
+type poolCollector struct {
+	db *pgxpool.Pool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConns
+	ch <- poolIdleConns
+	ch <- poolTotalConns
+	ch <- poolMaxConns
+	ch <- poolAcquires
+	ch <- poolAcquireSeconds
+	ch <- poolEmptyAcquires
+	ch <- poolCanceledAcquires
+	ch <- poolNewConns
+	ch <- poolMaxLifetimeCloses
+	ch <- poolMaxIdleCloses
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.db.Stat()
+
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(poolAcquiredConns, float64(stat.AcquiredConns()))
+	gauge(poolIdleConns, float64(stat.IdleConns()))
+	gauge(poolTotalConns, float64(stat.TotalConns()))
+	gauge(poolMaxConns, float64(stat.MaxConns()))
+	counter(poolAcquires, float64(stat.AcquireCount()))
+	counter(poolAcquireSeconds, stat.AcquireDuration().Seconds())
+	counter(poolEmptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(poolCanceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(poolNewConns, float64(stat.NewConnsCount()))
+	counter(poolMaxLifetimeCloses, float64(stat.MaxLifetimeDestroyCount()))
+	counter(poolMaxIdleCloses, float64(stat.MaxIdleDestroyCount()))
+}
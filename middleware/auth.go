@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"fampay-assignment/authtoken"
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithError(ctx *gin.Context, err lib.ExternalError) {
+	ctx.AbortWithStatusJSON(int(err.Code), lib.NewErrorApiResponse(err.Message))
+}
+
+// Authenticate requires a bearer token granting scope. Missing, malformed,
+// expired or unknown tokens get a 401, valid tokens lacking the scope a 403.
+func Authenticate(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || strings.TrimSpace(token) == "" {
+			ctx.Header("WWW-Authenticate", `Bearer realm="videos"`)
+			abortWithError(ctx, lib.NewExternalError().Unauthorized("missing bearer token"))
+			return
+		}
+
+		claims, err := lib.AuthenticateToken(strings.TrimSpace(token))
+		if err != nil {
+			logger.Log.WithFields(logger.Fields{
+				"path": ctx.Request.URL.Path,
+				"err":  err,
+			}).Warn("rejected token")
+			message := "invalid token"
+			if errors.Is(err, authtoken.ErrTokenExpired) {
+				message = "token expired"
+			}
+			ctx.Header("WWW-Authenticate", `Bearer realm="videos", error="invalid_token"`)
+			abortWithError(ctx, lib.NewExternalError().Unauthorized(message))
+			return
+		}
+
+		if !claims.Allows(scope) {
+			logger.Log.WithFields(logger.Fields{
+				"path":    ctx.Request.URL.Path,
+				"subject": claims.Subject,
+				"scope":   claims.Scope,
+			}).Warn("token lacks scope")
+			abortWithError(ctx, lib.NewExternalError().Forbidden("token does not grant "+scope+" access"))
+			return
+		}
+
+		ctx.Set("auth_subject", claims.Subject)
+		ctx.Set("auth_scope", claims.Scope)
+		ctx.Next()
+	}
+}
+
+func AdminAuth() gin.HandlerFunc {
+	return Authenticate(authtoken.ScopeAdmin)
+}
+
+func ReadAuth() gin.HandlerFunc {
+	return Authenticate(authtoken.ScopeRead)
+}
+
+// MetricsAuth admits metrics and admin tokens, so scrapers need no access to
+// the API itself.
+func MetricsAuth() gin.HandlerFunc {
+	return Authenticate(authtoken.ScopeMetrics)
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"slices"
 
+	"fampay-assignment/config"
 	"fampay-assignment/lib"
 	"fampay-assignment/logger"
-	"fampay-assignment/config"
-	
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	timeout "github.com/vearne/gin-timeout"
@@ -17,14 +18,14 @@ import (
 func RequestLogger() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		path := ctx.Request.URL.Path
-		
+
 		query := ctx.Request.URL.Query()
 		body, _ := io.ReadAll(ctx.Request.Body)
 
 		logger.Log.WithFields(logger.Fields{
-			"path":    path,
-			"query":   query,
-			"body":    string(body),
+			"path":  path,
+			"query": query,
+			"body":  string(body),
 		}).Info("request received")
 
 		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
@@ -32,15 +33,17 @@ func RequestLogger() gin.HandlerFunc {
 	}
 }
 
-
+// Cors only allows credentials for an explicit ALLOWED_ORIGINS list; tokens
+// travel in the Authorization header so wildcard origins never need them.
 func Cors() gin.HandlerFunc {
-    return cors.New(cors.Config{
-        AllowOrigins:     config.AllowedOrigins,
+	allowAllOrigins := slices.Contains(config.AllowedOrigins, "*")
+	return cors.New(cors.Config{
+		AllowOrigins:     config.AllowedOrigins,
 		AllowMethods:     config.CORS_ALLOWED_METHODS,
 		AllowHeaders:     config.CORS_ALLOWED_HEADERS,
-		AllowCredentials: true,
+		AllowCredentials: !allowAllOrigins,
 		AllowWildcard:    true,
-    })
+	})
 }
 
 func Timeout() gin.HandlerFunc {
@@ -53,4 +56,4 @@ func Timeout() gin.HandlerFunc {
 			}).Error("request timeout")
 			ctx.Abort()
 		}))
-}
\ No newline at end of file
+}
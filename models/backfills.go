@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	BackfillPending   = "pending"
+	BackfillRunning   = "running"
+	BackfillPaused    = "paused"
+	BackfillCompleted = "completed"
+)
+
+type BackfillJob struct {
+	ID          int       `db:"id"`
+	QueryID     int       `db:"query_id"`
+	Query       string    `db:"query"`
+	RangeStart  time.Time `db:"range_start"`
+	RangeEnd    time.Time `db:"range_end"`
+	Cursor      time.Time `db:"cursor"`
+	Status      string    `db:"status"`
+	QuotaBudget int       `db:"quota_budget"`
+	UnitsUsed   int       `db:"units_used"`
+	Pages       int       `db:"pages"`
+	Inserted    int       `db:"inserted"`
+	LastError   *string   `db:"last_error"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+type ChannelRule struct {
+	ID           int       `db:"id"`
+	List         string    `db:"list"`
+	ChannelID    *string   `db:"channel_id"`
+	TitlePattern *string   `db:"title_pattern"`
+	Note         *string   `db:"note"`
+	CreatedAt    time.Time `db:"created_at"`
+}
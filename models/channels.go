@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+type Channel struct {
+	ChannelID    string     `db:"channel_id"`
+	Title        string     `db:"title"`
+	Description  *string    `db:"description"`
+	CustomURL    *string    `db:"custom_url"`
+	ThumbnailURL *string    `db:"thumbnail_url"`
+	Country      *string    `db:"country"`
+	PublishedAt  *time.Time `db:"published_at"`
+
+	// nil until channels.list has been called for the channel; the
+	// subscriber count stays nil for channels that hide it
+	SubscriberCount  *int64     `db:"subscriber_count"`
+	VideoCount       *int64     `db:"video_count"`
+	ViewCount        *int64     `db:"view_count"`
+	DetailsUpdatedAt *time.Time `db:"details_updated_at"`
+
+	// videos of the channel stored by the fetcher
+	StoredVideos  int64      `db:"stored_videos"`
+	LatestVideoAt *time.Time `db:"latest_video_at"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
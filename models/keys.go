@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	KeyActive         = "active"
+	KeyQuotaExhausted = "quota_exhausted"
+	KeyInvalid        = "invalid"
+	KeyDisabled       = "disabled"
+)
+
+type YouTubeAPIKey struct {
+	ID             int        `db:"id"`
+	Key            string     `db:"key"`
+	State          string     `db:"state"`
+	ExhaustedUntil *time.Time `db:"exhausted_until"`
+	LastError      *string    `db:"last_error"`
+	LastErrorAt    *time.Time `db:"last_error_at"`
+	UsageCount     int64      `db:"usage_count"`
+	ErrorCount     int64      `db:"error_count"`
+	LastUsedAt     *time.Time `db:"last_used_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+type TrackedQuery struct {
+	ID        int        `db:"id"`
+	Query     string     `db:"query"`
+	Active    bool       `db:"active"`
+	Watermark *time.Time `db:"watermark"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+}
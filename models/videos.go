@@ -12,4 +12,19 @@ type Video struct {
 	ThumbnailURL string    `db:"thumbnail_url"`
 	ChannelTitle string    `db:"channel_title"`
 	ChannelID    string    `db:"channel_id"`
+
+	// filled in by the enrichment stage from videos.list, nil until then
+	ViewCount       *int64     `db:"view_count"`
+	LikeCount       *int64     `db:"like_count"`
+	CommentCount    *int64     `db:"comment_count"`
+	DurationSeconds *int       `db:"duration_seconds"`
+	Definition      *string    `db:"definition"`
+	HasCaptions     *bool      `db:"has_captions"`
+	StatsUpdatedAt  *time.Time `db:"stats_updated_at"`
+}
+
+// ScoredVideo is a search hit together with its relevance score.
+type ScoredVideo struct {
+	Video
+	Score float64 `db:"score"`
 }
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"fampay-assignment/config"
+	"fampay-assignment/controllers"
+	"fampay-assignment/lib"
+	"fampay-assignment/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Channels(engine *gin.Engine) *gin.Engine {
+	channels := engine.Group("/channels")
+	if config.ProtectVideos {
+		channels.Use(middleware.ReadAuth())
+	}
+
+	channels.GET("/", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListChannels", controllers.ListChannels)
+	})
+
+	channels.GET("/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "GetChannel", controllers.GetChannel)
+	})
+
+	channels.GET("/:id/videos", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListChannelVideos", controllers.ListChannelVideos)
+	})
+
+	return engine
+}
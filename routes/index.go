@@ -1,14 +1,20 @@
 package routes
 
 import (
+	"fampay-assignment/connections"
+	"fampay-assignment/lib"
+	"fampay-assignment/metrics"
 	"fampay-assignment/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func Router() *gin.Engine {
 	e := gin.New()
 
+	e.Use(middleware.Metrics())
+	e.Use(middleware.Tracing())
 	e.Use(middleware.Cors())
 	e.Use(middleware.Timeout())
 	e.Use(
@@ -18,6 +24,13 @@ func Router() *gin.Engine {
 	e.Use(middleware.RequestLogger())
 
 	Videos(e)
+	Channels(e)
+
+	if db, ok := connections.GetPostgresDb(); ok {
+		metrics.RegisterPostgresPool(db)
+	}
+	metrics.RegisterAPIKeyPool(lib.ApiKeys.CountByState)
+	e.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(promhttp.Handler()))
 	
 	return e
 }
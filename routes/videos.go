@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"fampay-assignment/config"
 	"fampay-assignment/controllers"
 	"fampay-assignment/lib"
+	"fampay-assignment/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,13 +12,117 @@ import (
 func Videos(engine *gin.Engine) *gin.Engine {
 	videos := engine.Group("/videos")
 
-	videos.GET("/", func(ctx *gin.Context) {
+	// listing videos is public unless PROTECT_VIDEOS asks for a read token
+	public := videos.Group("")
+	if config.ProtectVideos {
+		public.Use(middleware.ReadAuth())
+	}
+
+	public.GET("/", func(ctx *gin.Context) {
 		lib.ControllerWrapper(ctx, "GetLatestVideos", controllers.GetLatestVideos)
 	})
 
-	videos.POST("/key", func(ctx *gin.Context) {
+	public.GET("/search", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "SearchVideos", controllers.SearchVideos)
+	})
+
+	public.GET("/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "GetVideo", controllers.GetVideo)
+	})
+
+	// everything that changes or reveals the fetcher's setup needs an admin token
+	admin := videos.Group("", middleware.AdminAuth())
+
+	// looking a video up on YouTube spends quota and stores the video
+	if config.VideoLookupFallback {
+		admin.POST("/:id/lookup", func(ctx *gin.Context) {
+			lib.ControllerWrapper(ctx, "LookupVideo", controllers.LookupVideo)
+		})
+	}
+
+	admin.POST("/key", func(ctx *gin.Context) {
 		lib.ControllerWrapper(ctx, "AddYoutubeAPIKey", controllers.AddYoutubeAPIKey)
 	})
 
+	admin.GET("/keys", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListAPIKeys", controllers.ListAPIKeys)
+	})
+
+	admin.PATCH("/keys/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "UpdateAPIKey", controllers.UpdateAPIKey)
+	})
+
+	admin.DELETE("/keys/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "DeleteAPIKey", controllers.DeleteAPIKey)
+	})
+
+	admin.POST("/keys/:id/test", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "TestAPIKey", controllers.TestAPIKey)
+	})
+
+	admin.GET("/quota", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "GetQuotaUsage", controllers.GetQuotaUsage)
+	})
+
+	admin.GET("/cache/stats", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "GetCacheStats", controllers.GetCacheStats)
+	})
+
+	admin.DELETE("/cache", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "FlushCache", controllers.FlushCache)
+	})
+
+	admin.DELETE("/cache/:query", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "FlushCache", controllers.FlushCache)
+	})
+
+	admin.GET("/queries", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListTrackedQueries", controllers.ListTrackedQueries)
+	})
+
+	admin.POST("/queries", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "CreateTrackedQuery", controllers.CreateTrackedQuery)
+	})
+
+	admin.PATCH("/queries/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "UpdateTrackedQuery", controllers.UpdateTrackedQuery)
+	})
+
+	admin.DELETE("/queries/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "DeleteTrackedQuery", controllers.DeleteTrackedQuery)
+	})
+
+	admin.GET("/channel-rules", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListChannelRules", controllers.ListChannelRules)
+	})
+
+	admin.POST("/channel-rules", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "CreateChannelRule", controllers.CreateChannelRule)
+	})
+
+	admin.DELETE("/channel-rules/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "DeleteChannelRule", controllers.DeleteChannelRule)
+	})
+
+	admin.POST("/channel-rules/purge", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "PurgeBlockedChannels", controllers.PurgeBlockedChannels)
+	})
+
+	admin.GET("/backfills", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ListBackfills", controllers.ListBackfills)
+	})
+
+	admin.POST("/backfills", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "CreateBackfill", controllers.CreateBackfill)
+	})
+
+	admin.GET("/backfills/:id", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "GetBackfill", controllers.GetBackfill)
+	})
+
+	admin.POST("/backfills/:id/resume", func(ctx *gin.Context) {
+		lib.ControllerWrapper(ctx, "ResumeBackfill", controllers.ResumeBackfill)
+	})
+
 	return engine
 }
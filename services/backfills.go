@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fampay-assignment/config"
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func backfillJobError(err error) error {
+	switch {
+	case errors.Is(err, lib.ErrBackfillJobNotFound):
+		return lib.NewExternalError().NotFound(err.Error())
+	case errors.Is(err, lib.ErrBackfillJobBusy):
+		return lib.NewExternalError().BadRequest(err.Error())
+	}
+	return err
+}
+
+func CreateBackfill(
+	db *pgxpool.Pool,
+	params *types.CreateBackfillRequest,
+) (
+	response types.BackfillJobResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	from, _ := time.Parse(config.DATE_FORMAT, params.From)
+	to, _ := time.Parse(config.DATE_FORMAT, params.To)
+	if !from.Before(to) {
+		return response, lib.NewExternalError().BadRequest("from must be before to")
+	}
+	quotaBudget := params.QuotaBudget
+	if quotaBudget == 0 {
+		quotaBudget = lib.DefaultBackfillQuotaBudget()
+	}
+
+	job, err := lib.CreateBackfillJob(db, strings.TrimSpace(params.Query), from, to, quotaBudget)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, err
+	}
+	return startBackfill(db, job.ID)
+}
+
+func ListBackfills(
+	ctx context.Context,
+	db *pgxpool.Pool,
+) (
+	response types.ListBackfillJobsResponse,
+	err error,
+) {
+	response.Jobs, err = lib.ListBackfillJobs(ctx, db)
+	if err != nil {
+		logger.Log.Error(err)
+	}
+	return response, err
+}
+
+func GetBackfill(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.BackfillJobResponse,
+	err error,
+) {
+	response.Job, err = lib.GetBackfillJob(db, id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, backfillJobError(err)
+	}
+	return response, nil
+}
+
+func ResumeBackfill(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.BackfillJobResponse,
+	err error,
+) {
+	return startBackfill(db, id)
+}
+
+func startBackfill(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.BackfillJobResponse,
+	err error,
+) {
+	response.Job, err = lib.ClaimBackfillJob(db, id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, backfillJobError(err)
+	}
+	lib.StartBackfillJob(db, response.Job)
+	return response, nil
+}
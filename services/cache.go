@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+)
+
+func hitRate(hits int64, misses int64) float64 {
+	if lookups := hits + misses; lookups > 0 {
+		return float64(hits) / float64(lookups)
+	}
+	return 0
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func toCacheLayerStats(stats lib.CacheLayerStats) types.CacheLayerStats {
+	return types.CacheLayerStats{
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		HitRate: hitRate(stats.Hits, stats.Misses),
+	}
+}
+
+func GetCacheStats() (
+	response types.GetCacheStatsResponse,
+	err error,
+) {
+	stats, err := lib.GetCacheStats()
+	if err != nil {
+		logger.Log.Error(err)
+		return response, err
+	}
+
+	response = types.GetCacheStatsResponse{
+		Backend:      stats.Backend,
+		LocalEnabled: stats.LocalEnabled,
+		LocalEntries: stats.LocalEntries,
+		Local:        toCacheLayerStats(stats.Local),
+		Shared:       toCacheLayerStats(stats.Shared),
+		Queries:      []types.QueryCacheStats{},
+	}
+	for _, query := range stats.Queries {
+		response.Queries = append(response.Queries, types.QueryCacheStats{
+			Query:            query.Query,
+			Hits:             query.Hits,
+			Misses:           query.Misses,
+			Errors:           query.Errors,
+			HitRate:          hitRate(query.Hits, query.Misses),
+			AvgHitLatencyMs:  milliseconds(query.AvgHitLatency),
+			AvgMissLatencyMs: milliseconds(query.AvgMissLatency),
+			Keys:             query.Usage.Keys,
+			Bytes:            query.Usage.Bytes,
+		})
+	}
+	return response, nil
+}
+
+// FlushCache drops the cached results of one query, or of all of them when
+// query is empty.
+func FlushCache(
+	query string,
+) (
+	response types.FlushCacheResponse,
+	err error,
+) {
+	response.Version, err = lib.InvalidateCache(query)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"query": query,
+		}).Error(err)
+		if errors.Is(err, lib.ErrUnknownCachedQuery) {
+			return response, lib.NewExternalError().NotFound(err.Error())
+		}
+		return response, err
+	}
+	response.Success = true
+	response.Query = query
+	return response, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func channelRuleError(err error) error {
+	switch {
+	case errors.Is(err, lib.ErrChannelRuleNotFound):
+		return lib.NewExternalError().NotFound(err.Error())
+	case errors.Is(err, lib.ErrChannelRuleExists):
+		return lib.NewExternalError().BadRequest(err.Error())
+	}
+	return err
+}
+
+func ListChannelRules(
+	ctx context.Context,
+	db *pgxpool.Pool,
+) (
+	response types.ListChannelRulesResponse,
+	err error,
+) {
+	response.Rules, err = lib.ListChannelRules(ctx, db)
+	if err != nil {
+		logger.Log.Error(err)
+	}
+	return response, err
+}
+
+func CreateChannelRule(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.CreateChannelRuleRequest,
+) (
+	response types.ChannelRuleResponse,
+	err error,
+) {
+	if params.ChannelID != nil {
+		channelID := strings.TrimSpace(*params.ChannelID)
+		params.ChannelID = &channelID
+	}
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	response.Rule, err = lib.CreateChannelRule(db, params.List, params.ChannelID, params.TitlePattern, params.Note)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, channelRuleError(err)
+	}
+
+	if params.Purge {
+		purge, err := PurgeBlockedChannels(ctx, db)
+		if err != nil {
+			return response, err
+		}
+		response.Purge = &purge
+	}
+	return response, nil
+}
+
+func DeleteChannelRule(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.DeleteChannelRuleResponse,
+	err error,
+) {
+	err = lib.DeleteChannelRule(db, id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, channelRuleError(err)
+	}
+	response.Success = true
+	return response, nil
+}
+
+func PurgeBlockedChannels(
+	ctx context.Context,
+	db *pgxpool.Pool,
+) (
+	response types.PurgeBlockedChannelsResponse,
+	err error,
+) {
+	response.Channels, response.PurgedVideos, err = lib.PurgeBlockedChannels(ctx, db)
+	if err != nil {
+		logger.Log.Error(err)
+		return response, err
+	}
+	logger.Log.WithFields(logger.Fields{
+		"channels": response.Channels,
+		"purged":   response.PurgedVideos,
+	}).Info("purged videos of blocked channels")
+	return response, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func ListChannels(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.ListChannelsRequest,
+) (
+	response types.ListChannelsResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	channelsResult := lib.ListChannels(ctx, db, &lib.ListChannelsQueryParams{
+		PaginationPage: params.PaginationPage,
+		PaginationSize: params.PaginationSize,
+		SortOrder:      params.SortOrder,
+		SortBy:         params.SortBy,
+		Title:          params.Title,
+	})
+	if channelsResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(channelsResult.Err)
+		return response, channelsResult.Err
+	}
+	response.Channels = channelsResult.Channels
+	response.Page = params.PaginationPage
+	response.PageSize = params.PaginationSize
+	response.HasMore = channelsResult.HasMore
+	return response, nil
+}
+
+func GetChannel(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.GetChannelRequest,
+) (
+	response types.GetChannelResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	channelResult := lib.GetChannel(ctx, db, &lib.GetChannelQueryParams{ChannelID: params.ChannelID})
+	if channelResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(channelResult.Err)
+		return response, channelResult.Err
+	}
+	if !channelResult.Found {
+		return response, lib.NewExternalError().NotFound(lib.ErrChannelNotFound.Error())
+	}
+	response.Channel = channelResult.Channel
+	return response, nil
+}
+
+// ListChannelVideos lists the stored videos of one channel with every filter
+// and pagination option of GetLatestVideos.
+func ListChannelVideos(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	channelID string,
+	params *types.GetLatestVideosRequest,
+) (
+	response types.GetLatestVideosResponse,
+	err error,
+) {
+	_, err = GetChannel(ctx, db, &types.GetChannelRequest{ChannelID: channelID})
+	if err != nil {
+		return response, err
+	}
+
+	params.ChannelIDs = []string{channelID}
+	return GetLatestVideos(ctx, db, params)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+	"fampay-assignment/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func apiKeyError(err error) error {
+	if errors.Is(err, lib.ErrAPIKeyNotFound) {
+		return lib.NewExternalError().NotFound(err.Error())
+	}
+	return err
+}
+
+func toAPIKey(keyUsage lib.KeyQuotaUsage) types.APIKey {
+	key := keyUsage.Key
+	return types.APIKey{
+		ID:             key.ID,
+		Key:            utils.MaskSecret(key.Key),
+		State:          key.State,
+		ExhaustedUntil: key.ExhaustedUntil,
+		LastError:      key.LastError,
+		LastErrorAt:    key.LastErrorAt,
+		UsageCount:     key.UsageCount,
+		ErrorCount:     key.ErrorCount,
+		LastUsedAt:     key.LastUsedAt,
+		UnitsUsedToday: keyUsage.UnitsUsed,
+		CallsToday:     keyUsage.Calls,
+		CreatedAt:      key.CreatedAt,
+	}
+}
+
+func GetQuotaUsage(
+	db *pgxpool.Pool,
+) (
+	response types.GetQuotaUsageResponse,
+	err error,
+) {
+	usage := lib.ApiKeys.Usage()
+
+	response = types.GetQuotaUsageResponse{
+		Day:              usage.Day,
+		ResetsAt:         usage.ResetsAt,
+		DailyQuotaPerKey: usage.DailyQuotaPerKey,
+		UnitsUsed:        usage.UnitsUsed,
+		UnitsRemaining:   usage.UnitsRemaining,
+		FetchInterval:    usage.FetchInterval.String(),
+		Keys:             []types.APIKeyQuotaUsage{},
+	}
+	for _, keyUsage := range usage.Keys {
+		response.Keys = append(response.Keys, types.APIKeyQuotaUsage{
+			ID:             keyUsage.Key.ID,
+			Key:            utils.MaskSecret(keyUsage.Key.Key),
+			State:          keyUsage.Key.State,
+			UnitsUsed:      keyUsage.UnitsUsed,
+			UnitsRemaining: keyUsage.UnitsRemaining,
+			Calls:          keyUsage.Calls,
+		})
+	}
+	return response, nil
+}
+
+func ListAPIKeys(
+	db *pgxpool.Pool,
+) (
+	response types.ListAPIKeysResponse,
+	err error,
+) {
+	response.Keys = []types.APIKey{}
+	for _, keyUsage := range lib.ApiKeys.Usage().Keys {
+		response.Keys = append(response.Keys, toAPIKey(keyUsage))
+	}
+	return response, nil
+}
+
+func UpdateAPIKey(
+	db *pgxpool.Pool,
+	params *types.UpdateAPIKeyRequest,
+) (
+	response types.APIKeyResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	keyUsage, err := lib.ApiKeys.SetEnabled(params.ID, *params.Enabled)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, apiKeyError(err)
+	}
+	response.Key = toAPIKey(keyUsage)
+	return response, nil
+}
+
+func DeleteAPIKey(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.DeleteAPIKeyResponse,
+	err error,
+) {
+	err = lib.ApiKeys.Remove(id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, apiKeyError(err)
+	}
+	response.Success = true
+	return response, nil
+}
+
+func TestAPIKey(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.TestAPIKeyResponse,
+	err error,
+) {
+	result, err := lib.TestAPIKey(ctx, id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, apiKeyError(err)
+	}
+	response = types.TestAPIKeyResponse{
+		Passed:    result.Passed,
+		Error:     result.Error,
+		LatencyMs: result.Latency.Milliseconds(),
+		Key:       toAPIKey(result.Key),
+	}
+	return response, nil
+}
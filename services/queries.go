@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"fampay-assignment/lib"
+	"fampay-assignment/logger"
+	types "fampay-assignment/types"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func trackedQueryError(err error) error {
+	switch {
+	case errors.Is(err, lib.ErrTrackedQueryNotFound):
+		return lib.NewExternalError().NotFound(err.Error())
+	case errors.Is(err, lib.ErrTrackedQueryExists):
+		return lib.NewExternalError().BadRequest(err.Error())
+	}
+	return err
+}
+
+func ListTrackedQueries(
+	ctx context.Context,
+	db *pgxpool.Pool,
+) (
+	response types.ListTrackedQueriesResponse,
+	err error,
+) {
+	response.Queries, err = lib.ListTrackedQueries(ctx, db)
+	if err != nil {
+		logger.Log.Error(err)
+	}
+	return response, err
+}
+
+func CreateTrackedQuery(
+	db *pgxpool.Pool,
+	params *types.CreateTrackedQueryRequest,
+) (
+	response types.TrackedQueryResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	active := params.Active == nil || *params.Active
+	response.Query, err = lib.CreateTrackedQuery(db, strings.TrimSpace(params.Query), active)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, trackedQueryError(err)
+	}
+	return response, nil
+}
+
+func UpdateTrackedQuery(
+	db *pgxpool.Pool,
+	params *types.UpdateTrackedQueryRequest,
+) (
+	response types.TrackedQueryResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	if params.Query != nil {
+		query := strings.TrimSpace(*params.Query)
+		params.Query = &query
+	}
+	response.Query, err = lib.UpdateTrackedQuery(db, params.ID, params.Query, params.Active)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, trackedQueryError(err)
+	}
+	return response, nil
+}
+
+func DeleteTrackedQuery(
+	db *pgxpool.Pool,
+	id int,
+) (
+	response types.DeleteTrackedQueryResponse,
+	err error,
+) {
+	err = lib.DeleteTrackedQuery(db, id)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"id": id,
+		}).Error(err)
+		return response, trackedQueryError(err)
+	}
+	response.Success = true
+	return response, nil
+}
@@ -1,17 +1,22 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"fampay-assignment/config"
 	"fampay-assignment/lib"
 	"fampay-assignment/logger"
 	types "fampay-assignment/types"
+	"fampay-assignment/utils"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func GetLatestVideos(
+	ctx context.Context,
 	db *pgxpool.Pool,
 	params *types.GetLatestVideosRequest,
 ) (
@@ -26,31 +31,253 @@ func GetLatestVideos(
 		}).Error(err)
 		return response, lib.NewExternalError().BadRequest(err.Error())
 	}
-	publishedAfter, _ := time.Parse(config.DATE_FORMAT,params.PublishedAfter)
-	videosResult := lib.GetLatestYouTubeVideos(
-		db,
-		&lib.GetLatestYouTubeVideoQueryParams{
-			SortOrder: params.SortOrder,
-			PaginationSize: params.PaginationSize,
-			PaginationPage: params.PaginationPage,
-			PublishedAfter: publishedAfter,
-		},
-	)
+	queryParams := &lib.GetLatestYouTubeVideoQueryParams{
+		SortOrder:      params.SortOrder,
+		SortBy:         params.SortBy,
+		PaginationSize: params.PaginationSize,
+		PaginationPage: params.PaginationPage,
+		QueryID:        params.QueryID,
+		Keyset:         params.PaginationPage == 0,
+
+		ChannelIDs:      params.ChannelIDs,
+		ChannelTitle:    params.ChannelTitle,
+		IncludeKeywords: params.IncludeKeywords,
+		ExcludeKeywords: params.ExcludeKeywords,
+		TrackedQuery:    strings.TrimSpace(params.TrackedQuery),
+	}
+	queryParams.PublishedAfter, _ = time.Parse(config.DATE_FORMAT, params.PublishedAfter)
+	if params.PublishedBefore != "" {
+		queryParams.PublishedBefore, _ = time.Parse(config.DATE_FORMAT, params.PublishedBefore)
+	}
+	if params.Cursor != "" {
+		cursor, err := lib.DecodeVideoCursor(params.Cursor)
+		if err == nil && cursor.SortOrder != params.SortOrder {
+			err = lib.ErrInvalidCursor
+		}
+		if err != nil {
+			logger.Log.WithFields(logger.Fields{
+				"params": params,
+			}).Error(err)
+			return response, lib.NewExternalError().BadRequest("cursor: " + err.Error() + ".")
+		}
+		queryParams.Cursor = &cursor
+	}
+
+	countChan := make(chan lib.CountYouTubeVideoQueryResult, 1)
+	go func() {
+		countChan <- lib.CountYouTubeVideos(ctx, db, queryParams)
+	}()
+	videosResult := lib.GetLatestYouTubeVideos(ctx, db, queryParams)
+	countResult := <-countChan
 
-	if videosResult.Err != nil{
+	if videosResult.Err != nil {
 		logger.Log.WithFields(
 			logger.Fields{
 				"params": params,
-			},	
-		).Error(err)
+			},
+		).Error(videosResult.Err)
+		return response, videosResult.Err
 	}
 	response.Videos = videosResult.Videos
 	if response.Videos == nil {
 		response = types.GetLatestVideosResponse{}
 	}
+	response.NextCursor, response.PrevCursor = videoCursors(queryParams, videosResult)
+
+	response.Page = params.PaginationPage
+	response.PageSize = params.PaginationSize
+	response.HasMore = videosResult.HasMore
+	if queryParams.Cursor != nil && queryParams.Cursor.Backward {
+		// a backward page always has the page it was reached from after it
+		response.HasMore = len(response.Videos) > 0
+	}
+	if countResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(countResult.Err)
+	} else {
+		totalPages := (countResult.Total + int64(params.PaginationSize) - 1) / int64(params.PaginationSize)
+		response.Total = &countResult.Total
+		response.TotalEstimated = countResult.Estimated
+		response.TotalPages = &totalPages
+	}
+	response.Filters = types.VideoFilters{
+		SortOrder:       params.SortOrder,
+		SortBy:          params.SortBy,
+		PublishedAfter:  params.PublishedAfter,
+		PublishedBefore: params.PublishedBefore,
+		ChannelIDs:      params.ChannelIDs,
+		ChannelTitle:    params.ChannelTitle,
+		IncludeKeywords: params.IncludeKeywords,
+		ExcludeKeywords: params.ExcludeKeywords,
+		QueryID:         params.QueryID,
+		TrackedQuery:    queryParams.TrackedQuery,
+	}
 	return response, err
 }
 
+// videoCursors returns the cursors of the pages after and before the result.
+// Page number requests sorted by publish time get a next cursor as well, so
+// clients can switch over to keyset pagination.
+func videoCursors(
+	params *lib.GetLatestYouTubeVideoQueryParams,
+	result lib.GetLatestYouTubeVideoQueryResult,
+) (next string, prev string) {
+	videos := result.Videos
+	if len(videos) == 0 || params.SortBy != "published_at" {
+		return "", ""
+	}
+	first := lib.NewVideoCursor(videos[0], params.SortOrder, true).Encode()
+	last := lib.NewVideoCursor(videos[len(videos)-1], params.SortOrder, false).Encode()
+
+	switch {
+	case !params.Keyset:
+		if result.HasMore {
+			next = last
+		}
+	case params.Cursor != nil && params.Cursor.Backward:
+		next = last
+		if result.HasMore {
+			prev = first
+		}
+	default:
+		if result.HasMore {
+			next = last
+		}
+		if params.Cursor != nil {
+			prev = first
+		}
+	}
+	return next, prev
+}
+
+func SearchVideos(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.SearchVideosRequest,
+) (
+	response types.SearchVideosResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+	terms := strings.TrimSpace(params.Query)
+	if params.Mode == lib.SearchModeFullText {
+		terms = lib.PrefixTSQuery(params.Query)
+	}
+	if terms == "" {
+		return response, lib.NewExternalError().BadRequest("q: must contain at least one word.")
+	}
+
+	var publishedAfter time.Time
+	if params.PublishedAfter != "" {
+		publishedAfter, _ = time.Parse(config.DATE_FORMAT, params.PublishedAfter)
+	}
+	videosResult := lib.SearchYouTubeVideos(
+		ctx,
+		db,
+		&lib.SearchYouTubeVideoQueryParams{
+			Mode:           params.Mode,
+			Terms:          terms,
+			SortBy:         params.SortBy,
+			SortOrder:      params.SortOrder,
+			PaginationSize: params.PaginationSize,
+			PaginationPage: params.PaginationPage,
+			PublishedAfter: publishedAfter,
+			RecencyWeight:  params.RecencyWeight,
+		},
+	)
+	if videosResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(videosResult.Err)
+		return response, videosResult.Err
+	}
+	response.Videos = videosResult.Videos
+	response.Page = params.PaginationPage
+	response.PageSize = params.PaginationSize
+	response.HasMore = videosResult.HasMore
+	return response, nil
+}
+
+func GetVideo(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.GetVideoRequest,
+) (
+	response types.GetVideoResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	videoResult := lib.GetYouTubeVideo(ctx, db, &lib.GetYouTubeVideoQueryParams{VideoID: params.VideoID})
+	if videoResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(videoResult.Err)
+		return response, videoResult.Err
+	}
+	if !videoResult.Found {
+		return response, lib.NewExternalError().NotFound(lib.ErrVideoNotFound.Error())
+	}
+	response.Video = videoResult.Video
+	return response, nil
+}
+
+// LookupVideo returns a stored video, fetching and storing it from YouTube
+// first when it is not stored yet.
+func LookupVideo(
+	ctx context.Context,
+	db *pgxpool.Pool,
+	params *types.GetVideoRequest,
+) (
+	response types.GetVideoResponse,
+	err error,
+) {
+	err = params.Validate()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().BadRequest(err.Error())
+	}
+
+	videoResult := lib.GetYouTubeVideo(ctx, db, &lib.GetYouTubeVideoQueryParams{VideoID: params.VideoID})
+	if videoResult.Err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(videoResult.Err)
+		return response, videoResult.Err
+	}
+	if videoResult.Found {
+		response.Video = videoResult.Video
+		return response, nil
+	}
+
+	response.Video, err = lib.FetchAndStoreYouTubeVideo(ctx, db, params.VideoID)
+	if errors.Is(err, lib.ErrVideoNotFound) {
+		return response, lib.NewExternalError().NotFound(err.Error())
+	}
+	if err != nil {
+		logger.Log.WithFields(logger.Fields{
+			"params": params,
+		}).Error(err)
+		return response, lib.NewExternalError().Unavailable("video is not stored and could not be fetched from YouTube")
+	}
+	return response, nil
+}
+
 func AddYoutubeAPIKey(
 	db *pgxpool.Pool,
 	params *types.AddYoutubeAPIKeyRequest,
@@ -65,11 +292,15 @@ func AddYoutubeAPIKey(
 		}).Error(err)
 		return response, lib.NewExternalError().BadRequest(err.Error())
 	}
-	response.Success, err = lib.AddKey(params.ApiKey)
+	keyUsage, err := lib.AddKey(params.ApiKey)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields{
-			"params": params,
+			"key": utils.MaskSecret(params.ApiKey),
 		}).Error(err)
+		return response, err
 	}
-	return response, err
-}
\ No newline at end of file
+	key := toAPIKey(keyUsage)
+	response.Success = true
+	response.Key = &key
+	return response, nil
+}
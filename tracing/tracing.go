@@ -0,0 +1,104 @@
+// Package tracing sets up OpenTelemetry tracing for the API, the fetcher, the
+// query cache and Postgres. Spans are exported as configured by
+// TRACING_EXPORTER; without it they are created but dropped.
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"fampay-assignment/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts every span of the service. It is usable before Init, the
+// global provider forwards its spans once Init installs one.
+var Tracer = otel.Tracer("fampay-assignment")
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch config.TracingExporter {
+	case config.TRACING_EXPORTER_STDOUT:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case config.TRACING_EXPORTER_OTLP:
+		// the standard OTEL_EXPORTER_OTLP_* env vars apply when
+		// OTLP_ENDPOINT is not set
+		options := []otlptracehttp.Option{}
+		if config.OtlpEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.OtlpEndpoint))
+		}
+		return otlptracehttp.New(ctx, options...)
+	}
+	return nil, nil
+}
+
+// Init installs the global tracer provider and propagator. The returned
+// shutdown flushes spans still buffered and must be called before exiting.
+func Init(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", config.TRACING_SERVICE_NAME),
+	))
+	if err != nil {
+		return func(context.Context) error { return nil }, errors.Join(err, exporter.Shutdown(ctx))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(config.TracingSampleRatio),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a child span of the span in ctx, or a new trace without one.
+// Background loops use it for the root span of each cycle.
+func Start(
+	ctx context.Context,
+	name string,
+	attributes ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartChild starts a span only under an existing one, so helpers shared
+// with untraced background work do not each start a trace of their own.
+func StartChild(
+	ctx context.Context,
+	name string,
+	attributes ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return Start(ctx, name, attributes...)
+}
+
+// End records err, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
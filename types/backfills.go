@@ -0,0 +1,32 @@
+package types
+
+import (
+	"fampay-assignment/config"
+	"fampay-assignment/models"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type CreateBackfillRequest struct {
+	Query       string `json:"query"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	QuotaBudget int    `json:"quota_budget"`
+}
+
+func (req CreateBackfillRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Query, validation.Required, validation.Length(1, 255)),
+		validation.Field(&req.From, validation.Required, validation.Date(config.DATE_FORMAT)),
+		validation.Field(&req.To, validation.Required, validation.Date(config.DATE_FORMAT)),
+		validation.Field(&req.QuotaBudget, validation.Min(0)),
+	)
+}
+
+type BackfillJobResponse struct {
+	Job models.BackfillJob `json:"job"`
+}
+
+type ListBackfillJobsResponse struct {
+	Jobs []models.BackfillJob `json:"jobs"`
+}
@@ -0,0 +1,37 @@
+package types
+
+type CacheLayerStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+type QueryCacheStats struct {
+	Query            string  `json:"query"`
+	Hits             int64   `json:"hits"`
+	Misses           int64   `json:"misses"`
+	Errors           int64   `json:"errors"`
+	HitRate          float64 `json:"hit_rate"`
+	AvgHitLatencyMs  float64 `json:"avg_hit_latency_ms"`
+	AvgMissLatencyMs float64 `json:"avg_miss_latency_ms"`
+	// entries of the query's current version in the cache backend
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+type GetCacheStatsResponse struct {
+	Backend      string          `json:"backend"`
+	LocalEnabled bool            `json:"local_enabled"`
+	LocalEntries int             `json:"local_entries"`
+	Local        CacheLayerStats `json:"local"`
+	// the cache backend shared by every instance
+	Shared  CacheLayerStats   `json:"shared"`
+	Queries []QueryCacheStats `json:"queries"`
+}
+
+type FlushCacheResponse struct {
+	Success bool `json:"success"`
+	// empty when every query was flushed
+	Query   string `json:"query,omitempty"`
+	Version int64  `json:"version"`
+}
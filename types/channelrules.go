@@ -0,0 +1,68 @@
+package types
+
+import (
+	"errors"
+	"regexp"
+
+	"fampay-assignment/models"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type CreateChannelRuleRequest struct {
+	List         string  `json:"list"`
+	ChannelID    *string `json:"channel_id"`
+	TitlePattern *string `json:"title_pattern"`
+	Note         *string `json:"note"`
+	// removes the stored videos of blocked channels once the rule is added
+	Purge bool `json:"purge"`
+}
+
+func compilesAsPattern(value interface{}) error {
+	pattern, _ := value.(*string)
+	if pattern == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(*pattern); err != nil {
+		return errors.New("must be a valid regular expression")
+	}
+	return nil
+}
+
+func (req CreateChannelRuleRequest) Validate() error {
+	err := validation.ValidateStruct(&req,
+		validation.Field(&req.List, validation.Required, validation.In("allow", "block")),
+		validation.Field(&req.ChannelID, validation.NilOrNotEmpty, validation.Match(channelIDPattern)),
+		validation.Field(&req.TitlePattern, validation.NilOrNotEmpty, validation.Length(1, 255), validation.By(compilesAsPattern)),
+		validation.Field(&req.Note, validation.Length(0, 500)),
+	)
+	if err != nil {
+		return err
+	}
+	if (req.ChannelID == nil) == (req.TitlePattern == nil) {
+		return errors.New("channel_id: exactly one of channel_id and title_pattern is required.")
+	}
+	if req.Purge && req.List != "block" {
+		return errors.New("purge: only applies to block rules.")
+	}
+	return nil
+}
+
+type ChannelRuleResponse struct {
+	Rule models.ChannelRule `json:"rule"`
+	// set when the request asked for a purge
+	Purge *PurgeBlockedChannelsResponse `json:"purge,omitempty"`
+}
+
+type ListChannelRulesResponse struct {
+	Rules []models.ChannelRule `json:"rules"`
+}
+
+type DeleteChannelRuleResponse struct {
+	Success bool `json:"success"`
+}
+
+type PurgeBlockedChannelsResponse struct {
+	Channels     []string `json:"channels"`
+	PurgedVideos int64    `json:"purged_videos"`
+}
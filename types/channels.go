@@ -0,0 +1,47 @@
+package types
+
+import (
+	"fampay-assignment/config"
+	"fampay-assignment/models"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type ListChannelsRequest struct {
+	SortOrder      string `json:"sort_order"`
+	SortBy         string `json:"sort_by"`
+	PaginationSize int    `json:"pagination_size"`
+	PaginationPage int    `json:"pagination_page"`
+	Title          string `json:"title"`
+}
+
+func (req ListChannelsRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.SortOrder, validation.Required, validation.In("asc", "desc")),
+		validation.Field(&req.SortBy, validation.Required, validation.In("subscriber_count", "title", "stored_videos", "latest_video_at")),
+		validation.Field(&req.PaginationSize, validation.Required, validation.Min(1), validation.Max(config.MAX_PAGINATION_SIZE)),
+		validation.Field(&req.PaginationPage, validation.Required, validation.Min(1)),
+		validation.Field(&req.Title, validation.Length(1, 255)),
+	)
+}
+
+type ListChannelsResponse struct {
+	Channels []models.Channel `json:"channels"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	HasMore  bool             `json:"has_more"`
+}
+
+type GetChannelRequest struct {
+	ChannelID string `json:"channel_id"`
+}
+
+func (req GetChannelRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.ChannelID, validation.Required, validation.Match(channelIDPattern)),
+	)
+}
+
+type GetChannelResponse struct {
+	Channel models.Channel `json:"channel"`
+}
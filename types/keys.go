@@ -0,0 +1,73 @@
+package types
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// APIKey is a key as shown to operators, with its value masked.
+type APIKey struct {
+	ID             int        `json:"id"`
+	Key            string     `json:"key"`
+	State          string     `json:"state"`
+	ExhaustedUntil *time.Time `json:"exhausted_until"`
+	LastError      *string    `json:"last_error"`
+	LastErrorAt    *time.Time `json:"last_error_at"`
+	UsageCount     int64      `json:"usage_count"`
+	ErrorCount     int64      `json:"error_count"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	UnitsUsedToday int        `json:"units_used_today"`
+	CallsToday     int        `json:"calls_today"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
+
+type APIKeyResponse struct {
+	Key APIKey `json:"key"`
+}
+
+type UpdateAPIKeyRequest struct {
+	ID      int   `json:"-"`
+	Enabled *bool `json:"enabled"`
+}
+
+func (req UpdateAPIKeyRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.ID, validation.Required, validation.Min(1)),
+		validation.Field(&req.Enabled, validation.NotNil),
+	)
+}
+
+type DeleteAPIKeyResponse struct {
+	Success bool `json:"success"`
+}
+
+type TestAPIKeyResponse struct {
+	Passed    bool   `json:"passed"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Key       APIKey `json:"key"`
+}
+
+type APIKeyQuotaUsage struct {
+	ID             int    `json:"id"`
+	Key            string `json:"key"`
+	State          string `json:"state"`
+	UnitsUsed      int    `json:"units_used"`
+	UnitsRemaining int    `json:"units_remaining"`
+	Calls          int    `json:"calls"`
+}
+
+type GetQuotaUsageResponse struct {
+	Day              string             `json:"day"`
+	ResetsAt         time.Time          `json:"resets_at"`
+	DailyQuotaPerKey int                `json:"daily_quota_per_key"`
+	UnitsUsed        int                `json:"units_used"`
+	UnitsRemaining   int                `json:"units_remaining"`
+	FetchInterval    string             `json:"fetch_interval"`
+	Keys             []APIKeyQuotaUsage `json:"keys"`
+}
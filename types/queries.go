@@ -0,0 +1,43 @@
+package types
+
+import (
+	"fampay-assignment/models"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type CreateTrackedQueryRequest struct {
+	Query  string `json:"query"`
+	Active *bool  `json:"active"`
+}
+
+func (req CreateTrackedQueryRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Query, validation.Required, validation.Length(1, 255)),
+	)
+}
+
+type UpdateTrackedQueryRequest struct {
+	ID     int     `json:"-"`
+	Query  *string `json:"query"`
+	Active *bool   `json:"active"`
+}
+
+func (req UpdateTrackedQueryRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.ID, validation.Required, validation.Min(1)),
+		validation.Field(&req.Query, validation.NilOrNotEmpty, validation.Length(1, 255)),
+	)
+}
+
+type TrackedQueryResponse struct {
+	Query models.TrackedQuery `json:"query"`
+}
+
+type ListTrackedQueriesResponse struct {
+	Queries []models.TrackedQuery `json:"queries"`
+}
+
+type DeleteTrackedQueryResponse struct {
+	Success bool `json:"success"`
+}
@@ -1,42 +1,154 @@
 package types
 
 import (
+	"errors"
+	"regexp"
+
 	"fampay-assignment/config"
 	"fampay-assignment/models"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 )
 
+var (
+	channelIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+	videoIDPattern   = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+)
+
 type GetLatestVideosRequest struct {
 	SortOrder      string `json:"sort_order"`
+	SortBy         string `json:"sort_by"`
 	PaginationSize int    `json:"pagination_size"`
 	PaginationPage int    `json:"pagination_page"`
 	PublishedAfter string `json:"published_after"`
+	QueryID        int    `json:"query_id"`
+	Cursor         string `json:"cursor"`
+
+	PublishedBefore string   `json:"published_before"`
+	ChannelIDs      []string `json:"channel_id"`
+	ChannelTitle    string   `json:"channel_title"`
+	IncludeKeywords []string `json:"include_keywords"`
+	ExcludeKeywords []string `json:"exclude_keywords"`
+	TrackedQuery    string   `json:"tracked_query"`
 }
 
+// Validate accepts either a pagination_page or keyset pagination, which starts
+// without a cursor and continues with the cursors of the previous response.
 func (req GetLatestVideosRequest) Validate() error {
-	return validation.ValidateStruct(&req,
+	err := validation.ValidateStruct(&req,
 		validation.Field(&req.SortOrder, validation.Required, validation.In("asc", "desc")),
+		validation.Field(&req.SortBy, validation.Required, validation.In("published_at", "view_count", "like_count", "comment_count")),
 		validation.Field(&req.PaginationSize, validation.Required, validation.Min(1), validation.Max(config.MAX_PAGINATION_SIZE)),
-		validation.Field(&req.PaginationPage, validation.Required, validation.Min(1)),
+		validation.Field(&req.PaginationPage, validation.Min(1)),
 		validation.Field(&req.PublishedAfter, validation.Date(config.DATE_FORMAT)),
+		validation.Field(&req.QueryID, validation.Min(0)),
+		validation.Field(&req.PublishedBefore, validation.Date(config.DATE_FORMAT)),
+		validation.Field(&req.ChannelIDs, validation.Length(0, config.MAX_FILTER_VALUES), validation.Each(validation.Match(channelIDPattern))),
+		validation.Field(&req.ChannelTitle, validation.Length(1, 255)),
+		validation.Field(&req.IncludeKeywords, validation.Length(0, config.MAX_FILTER_VALUES), validation.Each(validation.Length(1, 100))),
+		validation.Field(&req.ExcludeKeywords, validation.Length(0, config.MAX_FILTER_VALUES), validation.Each(validation.Length(1, 100))),
+		validation.Field(&req.TrackedQuery, validation.Length(1, 255)),
 	)
+	if err != nil {
+		return err
+	}
+	if req.PublishedAfter != "" && req.PublishedBefore != "" && req.PublishedBefore <= req.PublishedAfter {
+		return errors.New("published_before: must be after published_after.")
+	}
+	if req.PaginationPage == 0 && req.SortBy != "published_at" {
+		return errors.New("pagination_page: cannot be blank unless sort_by is published_at.")
+	}
+	if req.Cursor != "" && req.PaginationPage != 0 {
+		return errors.New("cursor: cannot be combined with pagination_page.")
+	}
+	return nil
+}
+
+// VideoFilters echoes the filters a listing was computed with, defaults
+// included.
+type VideoFilters struct {
+	SortOrder       string   `json:"sort_order"`
+	SortBy          string   `json:"sort_by"`
+	PublishedAfter  string   `json:"published_after"`
+	PublishedBefore string   `json:"published_before,omitempty"`
+	ChannelIDs      []string `json:"channel_id,omitempty"`
+	ChannelTitle    string   `json:"channel_title,omitempty"`
+	IncludeKeywords []string `json:"include_keywords,omitempty"`
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+	QueryID         int      `json:"query_id,omitempty"`
+	TrackedQuery    string   `json:"tracked_query,omitempty"`
 }
 
 type GetLatestVideosResponse struct {
 	Videos []models.Video `json:"videos"`
+	// nil when the count could not be computed in time
+	Total          *int64       `json:"total"`
+	TotalEstimated bool         `json:"total_estimated"`
+	TotalPages     *int64       `json:"total_pages"`
+	Page           int          `json:"page,omitempty"`
+	PageSize       int          `json:"page_size"`
+	HasMore        bool         `json:"has_more"`
+	Filters        VideoFilters `json:"filters"`
+	NextCursor     string       `json:"next_cursor,omitempty"`
+	PrevCursor     string       `json:"prev_cursor,omitempty"`
+}
+
+type SearchVideosRequest struct {
+	Query          string  `json:"q"`
+	Mode           string  `json:"mode"`
+	SortOrder      string  `json:"sort_order"`
+	SortBy         string  `json:"sort_by"`
+	PaginationSize int     `json:"pagination_size"`
+	PaginationPage int     `json:"pagination_page"`
+	PublishedAfter string  `json:"published_after"`
+	RecencyWeight  float64 `json:"recency_weight"`
+}
+
+func (req SearchVideosRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Query, validation.Required, validation.Length(1, 200)),
+		validation.Field(&req.Mode, validation.Required, validation.In("fulltext", "fuzzy")),
+		validation.Field(&req.SortOrder, validation.Required, validation.In("asc", "desc")),
+		validation.Field(&req.SortBy, validation.Required, validation.In("relevance", "published_at", "view_count", "like_count", "comment_count")),
+		validation.Field(&req.PaginationSize, validation.Required, validation.Min(1), validation.Max(config.MAX_PAGINATION_SIZE)),
+		validation.Field(&req.PaginationPage, validation.Required, validation.Min(1)),
+		validation.Field(&req.PublishedAfter, validation.Date(config.DATE_FORMAT)),
+		validation.Field(&req.RecencyWeight, validation.Min(0.0), validation.Max(1.0)),
+	)
+}
+
+type SearchVideosResponse struct {
+	Videos   []models.ScoredVideo `json:"videos"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+	HasMore  bool                 `json:"has_more"`
+}
+
+type GetVideoRequest struct {
+	VideoID string `json:"video_id"`
+}
+
+func (req GetVideoRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.VideoID, validation.Required, validation.Match(videoIDPattern)),
+	)
+}
+
+type GetVideoResponse struct {
+	Video models.Video `json:"video"`
 }
 
 type AddYoutubeAPIKeyRequest struct {
-	ApiKey string 	`json:"api_key"`
+	ApiKey string `json:"api_key"`
 }
 
 type AddYoutubeAPIKeyResponse struct {
-	Success bool `json:"success"`
+	Success bool    `json:"success"`
+	Key     *APIKey `json:"key,omitempty"`
 }
 
 func (req AddYoutubeAPIKeyRequest) Validate() error {
 	return validation.ValidateStruct(&req,
 		validation.Field(&req.ApiKey, validation.Required),
 	)
-}
\ No newline at end of file
+}
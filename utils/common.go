@@ -1,12 +1,16 @@
 package utils
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var iso8601DurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
 func GetPaginationOffset(page int, size int) int {
 	return (page - 1) * size
 }
@@ -24,22 +28,34 @@ func GetContextWithTimeout(ctx context.Context, timeout time.Duration) context.C
 	return Ctx
 }
 
-func EncodeToGob(data any) ([]byte, error) {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(data)
-	if err != nil {
-		return nil, err
+// ParseISO8601Duration parses the day-time subset of ISO 8601 durations used
+// by the YouTube API, e.g. "PT1H2M3S" or "P1DT2H".
+func ParseISO8601Duration(value string) (time.Duration, error) {
+	matches := iso8601DurationPattern.FindStringSubmatch(value)
+	if matches == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", value)
+	}
+
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+		count, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(count) * unit
 	}
-	return buffer.Bytes(), nil
+	return duration, nil
 }
 
-func DecodeFromGob(data []byte, result any) error {
-	buffer := bytes.NewBuffer(data)
-	decoder := gob.NewDecoder(buffer)
-	err := decoder.Decode(result)
-	if err != nil {
-		return err
+// MaskSecret keeps only the first and last four characters of a secret so it
+// can be shown back to operators.
+func MaskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
 	}
-	return nil
-}
\ No newline at end of file
+	return secret[:4] + strings.Repeat("*", len(secret)-8) + secret[len(secret)-4:]
+}
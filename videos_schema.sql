@@ -1,3 +1,6 @@
+-- Trigram matching for fuzzy search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE videos (
     video_id VARCHAR(50) NOT NULL UNIQUE,
     title TEXT NOT NULL,
@@ -5,9 +8,132 @@ CREATE TABLE videos (
     published_at TIMESTAMPTZ NOT NULL,
     thumbnail_url TEXT,
     channel_title VARCHAR(255),
-    channel_id VARCHAR(50) NOT NULL
+    channel_id VARCHAR(50) NOT NULL,
+    -- enrichment from videos.list, NULL until the video has been enriched
+    view_count BIGINT,
+    like_count BIGINT,
+    comment_count BIGINT,
+    duration_seconds INTEGER,
+    definition VARCHAR(10),
+    has_captions BOOLEAN,
+    stats_updated_at TIMESTAMPTZ,
+    -- full-text search document, titles weigh more than descriptions
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED
 );
 
 -- Indexes
 CREATE INDEX idx_videos_video_id ON videos(video_id);
 CREATE INDEX idx_videos_published_at ON videos(published_at);
+-- keyset pagination order
+CREATE INDEX idx_videos_published_at_video_id ON videos(published_at, video_id);
+CREATE INDEX idx_videos_view_count ON videos(view_count);
+CREATE INDEX idx_videos_like_count ON videos(like_count);
+CREATE INDEX idx_videos_comment_count ON videos(comment_count);
+CREATE INDEX idx_videos_stats_updated_at ON videos(stats_updated_at);
+CREATE INDEX idx_videos_channel_id_published_at ON videos(channel_id, published_at);
+CREATE INDEX idx_videos_search_vector ON videos USING GIN (search_vector);
+CREATE INDEX idx_videos_title_trgm ON videos USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_videos_channel_title_trgm ON videos USING GIN (channel_title gin_trgm_ops);
+CREATE INDEX idx_videos_description_trgm ON videos USING GIN (description gin_trgm_ops);
+
+-- Publishers of stored videos; the fetcher records channel ids and titles,
+-- the enrichment stage fills in the rest from channels.list
+CREATE TABLE channels (
+    channel_id VARCHAR(50) PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    custom_url VARCHAR(255),
+    thumbnail_url TEXT,
+    country VARCHAR(10),
+    published_at TIMESTAMPTZ,
+    -- NULL when the channel hides its subscriber count
+    subscriber_count BIGINT,
+    video_count BIGINT,
+    view_count BIGINT,
+    details_updated_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_channels_subscriber_count ON channels(subscriber_count);
+CREATE INDEX idx_channels_details_updated_at ON channels(details_updated_at);
+
+-- Ingestion allow/block lists; a rule matches a channel id or a regular
+-- expression (RE2 syntax) against the channel title, never both
+CREATE TABLE channel_rules (
+    id SERIAL PRIMARY KEY,
+    list VARCHAR(10) NOT NULL CHECK (list IN ('allow', 'block')),
+    channel_id VARCHAR(50),
+    title_pattern VARCHAR(255),
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((channel_id IS NULL) <> (title_pattern IS NULL)),
+    UNIQUE (list, channel_id),
+    UNIQUE (list, title_pattern)
+);
+
+CREATE TABLE tracked_queries (
+    id SERIAL PRIMARY KEY,
+    query VARCHAR(255) NOT NULL UNIQUE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- publishedAfter cursor the next fetch cycle resumes from
+    watermark TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Which tracked queries surfaced a video; a video can match several queries
+CREATE TABLE video_queries (
+    video_id VARCHAR(50) NOT NULL REFERENCES videos(video_id) ON DELETE CASCADE,
+    query_id INTEGER NOT NULL REFERENCES tracked_queries(id) ON DELETE CASCADE,
+    surfaced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (video_id, query_id)
+);
+
+CREATE INDEX idx_video_queries_query_id ON video_queries(query_id);
+
+-- Historical backfills walk [range_start, range_end) backwards in windows;
+-- everything published after cursor has already been fetched
+CREATE TABLE backfill_jobs (
+    id SERIAL PRIMARY KEY,
+    query_id INTEGER NOT NULL REFERENCES tracked_queries(id) ON DELETE CASCADE,
+    range_start TIMESTAMPTZ NOT NULL,
+    range_end TIMESTAMPTZ NOT NULL,
+    cursor TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    quota_budget INTEGER NOT NULL,
+    units_used INTEGER NOT NULL DEFAULT 0,
+    pages INTEGER NOT NULL DEFAULT 0,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- YouTube Data API keys the fetcher rotates through; quota_exhausted keys
+-- come back once exhausted_until (the next Pacific midnight) has passed
+CREATE TABLE youtube_api_keys (
+    id SERIAL PRIMARY KEY,
+    key TEXT NOT NULL UNIQUE,
+    state VARCHAR(20) NOT NULL DEFAULT 'active',
+    exhausted_until TIMESTAMPTZ,
+    last_error TEXT,
+    last_error_at TIMESTAMPTZ,
+    usage_count BIGINT NOT NULL DEFAULT 0,
+    error_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Estimated quota units spent per key per quota day (Pacific time)
+CREATE TABLE youtube_api_key_usage (
+    key_id INTEGER NOT NULL REFERENCES youtube_api_keys(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    units INTEGER NOT NULL DEFAULT 0,
+    calls INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);